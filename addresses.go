@@ -1,5 +1,13 @@
 package config
 
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
 // Addresses stores the (string) multiaddr addresses for the node.
 type Addresses struct {
 	Swarm      []string // addresses for the swarm to listen on
@@ -8,4 +16,61 @@ type Addresses struct {
 	API        Strings  // address for the local API (RPC)
 	Gateway    Strings  // address to listen on for BTFS HTTP object gateway
 	RemoteAPI  Strings  // address to listen for remote API (RPC over libp2p)
+
+	GatewayHTTPS Strings `json:",omitempty"` // address to listen on for the HTTPS gateway, see Gateway.TLS
+}
+
+// parseIPCIDR parses a multiaddr CIDR such as `/ip4/10.0.0.0/ipcidr/8` into
+// an IP network.
+func parseIPCIDR(s string) (*net.IPNet, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[3] != "ipcidr" {
+		return nil, fmt.Errorf("invalid multiaddr CIDR: %s", s)
+	}
+	ip := net.ParseIP(parts[2])
+	switch {
+	case ip == nil:
+		return nil, fmt.Errorf("invalid multiaddr CIDR: %s", s)
+	case parts[1] == "ip4" && ip.To4() == nil, parts[1] == "ip6" && ip.To4() != nil:
+		return nil, fmt.Errorf("invalid multiaddr CIDR: %s", s)
+	case parts[1] != "ip4" && parts[1] != "ip6":
+		return nil, fmt.Errorf("invalid multiaddr CIDR: %s", s)
+	}
+	_, ipnet, err := net.ParseCIDR(parts[2] + "/" + parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("invalid multiaddr CIDR: %s", s)
+	}
+	return ipnet, nil
+}
+
+// isLocalAddr reports whether a multiaddr only accepts connections from the
+// local machine: a loopback IP, localhost, or a unix socket.
+func isLocalAddr(addr string) bool {
+	parts := strings.Split(addr, "/")
+	if len(parts) < 3 {
+		return false
+	}
+	switch parts[1] {
+	case "ip4", "ip6":
+		ip := net.ParseIP(parts[2])
+		return ip != nil && ip.IsLoopback()
+	case "dns", "dns4", "dns6":
+		return parts[2] == "localhost"
+	case "unix":
+		return true
+	default:
+		return false
+	}
+}
+
+// validateProtocolName checks that name is a multiaddr protocol name, such as
+// "tcp" or "quic", known to the linked go-multiaddr.
+func validateProtocolName(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty protocol name")
+	}
+	if ma.ProtocolWithName(name).Code == 0 {
+		return fmt.Errorf("unknown multiaddr protocol: %s", name)
+	}
+	return nil
 }
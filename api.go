@@ -1,5 +1,176 @@
 package config
 
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
 type API struct {
 	HTTPHeaders map[string][]string // HTTP headers to return with the API.
+
+	// CORS configures cross-origin requests to the API.
+	CORS *CORS `json:",omitempty"`
+
+	// Authorizations maps a credential name to the credential and the
+	// commands it may call. When empty, the API does not require
+	// authentication.
+	Authorizations map[string]*APIAuthorization `json:",omitempty"`
+}
+
+const (
+	// APIAuthBearer authenticates with an `Authorization: Bearer <token>`
+	// header.
+	APIAuthBearer = "bearer"
+	// APIAuthBasic authenticates with HTTP basic authentication.
+	APIAuthBasic = "basic"
+)
+
+// APIAuthorization is a credential allowed to call the API.
+type APIAuthorization struct {
+	// Scheme is either "bearer" or "basic".
+	Scheme string
+
+	// Username is the basic authentication user name.
+	Username string `json:",omitempty"`
+
+	// SecretHash is the bcrypt hash of the token or password, see
+	// HashAPISecret. The plain secret is never stored.
+	SecretHash string
+
+	// AllowedPaths lists the command path prefixes this credential may
+	// call, e.g. `/api/v1/cat` or `/api/v1/files`. Use `/api/v1` or `/` to
+	// allow every command.
+	AllowedPaths []string
+}
+
+// maxAPISecretLen is the longest secret bcrypt hashes without truncating it.
+const maxAPISecretLen = 72
+
+// HashAPISecret hashes a token or password with bcrypt for
+// APIAuthorization.SecretHash. Checking a secret is deliberately slow, so
+// servers should cache successful checks rather than verify every request.
+func HashAPISecret(secret string) (string, error) {
+	if len(secret) > maxAPISecretLen {
+		return "", fmt.Errorf("secret must not be longer than %d bytes", maxAPISecretLen)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func validateAPISecretHash(hash string) error {
+	if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+		return fmt.Errorf("invalid secret hash, expected a bcrypt hash: %s", err)
+	}
+	return nil
+}
+
+// verifySecret compares a plain secret against the stored hash.
+func (a *APIAuthorization) verifySecret(secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(a.SecretHash), []byte(secret)) == nil
+}
+
+// allowsPath reports whether urlPath is below one of the AllowedPaths. Paths
+// that are not clean, such as `/api/v1/cat/../config`, are never allowed.
+func (a *APIAuthorization) allowsPath(urlPath string) bool {
+	if _, ok := commandSegments(urlPath); !ok {
+		return false
+	}
+	urlPath = strings.TrimSuffix(urlPath, "/")
+	for _, allowed := range a.AllowedPaths {
+		allowed = path.Clean(allowed)
+		if allowed == "/" || urlPath == allowed || strings.HasPrefix(urlPath, allowed+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyAuthorization checks the value of an `Authorization` HTTP header
+// against the configured credentials and the requested command path, e.g.
+// `/api/v1/cat`. It returns the name of the matching credential. When no
+// credentials are configured, every request is allowed.
+func (a *API) VerifyAuthorization(header, path string) (name string, ok bool) {
+	if len(a.Authorizations) == 0 {
+		return "", true
+	}
+
+	var scheme, username, secret string
+	switch {
+	case len(header) > 7 && strings.EqualFold(header[:7], "Bearer "):
+		scheme, secret = APIAuthBearer, header[7:]
+	case len(header) > 6 && strings.EqualFold(header[:6], "Basic "):
+		decoded, err := base64.StdEncoding.DecodeString(header[6:])
+		if err != nil {
+			return "", false
+		}
+		idx := strings.IndexByte(string(decoded), ':')
+		if idx < 0 {
+			return "", false
+		}
+		scheme, username, secret = APIAuthBasic, string(decoded[:idx]), string(decoded[idx+1:])
+	default:
+		return "", false
+	}
+
+	for name, auth := range a.Authorizations {
+		if auth == nil || auth.Scheme != scheme {
+			continue
+		}
+		if scheme == APIAuthBasic && subtle.ConstantTimeCompare([]byte(auth.Username), []byte(username)) != 1 {
+			continue
+		}
+		if auth.verifySecret(secret) {
+			return name, auth.allowsPath(path)
+		}
+	}
+	return "", false
+}
+
+// EffectiveHTTPHeaders returns HTTPHeaders with the CORS section rendered
+// into it.
+func (a *API) EffectiveHTTPHeaders() map[string][]string {
+	return withCORSHeaders(a.HTTPHeaders, a.CORS)
+}
+
+// Validate checks the CORS section and the configured credentials.
+func (a *API) Validate() error {
+	if a.CORS != nil {
+		if err := a.CORS.Validate(); err != nil {
+			return fmt.Errorf("CORS: %s", err)
+		}
+	}
+	for name, auth := range a.Authorizations {
+		if auth == nil {
+			return fmt.Errorf("Authorizations[%s]: missing credential", name)
+		}
+		switch auth.Scheme {
+		case APIAuthBearer:
+		case APIAuthBasic:
+			if auth.Username == "" || strings.Contains(auth.Username, ":") {
+				return fmt.Errorf("Authorizations[%s]: basic authentication requires a username without ':'", name)
+			}
+		default:
+			return fmt.Errorf("Authorizations[%s]: unknown scheme %q", name, auth.Scheme)
+		}
+		if err := validateAPISecretHash(auth.SecretHash); err != nil {
+			return fmt.Errorf("Authorizations[%s]: %s", name, err)
+		}
+		if len(auth.AllowedPaths) == 0 {
+			return fmt.Errorf("Authorizations[%s]: AllowedPaths must not be empty", name)
+		}
+		for _, p := range auth.AllowedPaths {
+			if p != "/" && (!strings.HasPrefix(p, "/") || path.Clean(p) != strings.TrimSuffix(p, "/")) {
+				return fmt.Errorf("Authorizations[%s]: invalid path %q", name, p)
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestAPIVerifyAuthorization(t *testing.T) {
+	tokenHash, err := HashAPISecret("s3cret-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordHash, err := HashAPISecret("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(tokenHash, "s3cret-token") {
+		t.Fatal("secret must not be stored in plain text")
+	}
+	if _, err := HashAPISecret(strings.Repeat("x", 73)); err == nil {
+		t.Fatal("expected secrets bcrypt would truncate to be rejected")
+	}
+
+	api := API{Authorizations: map[string]*APIAuthorization{
+		"reader": {Scheme: APIAuthBearer, SecretHash: tokenHash, AllowedPaths: []string{"/api/v1/cat", "/api/v1/files/"}},
+		"admin":  {Scheme: APIAuthBasic, Username: "admin", SecretHash: passwordHash, AllowedPaths: []string{"/api/v1"}},
+	}}
+	if err := api.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	basic := func(user, pass string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+	}
+	for _, tc := range []struct {
+		header, path, name string
+		ok                 bool
+	}{
+		{"Bearer s3cret-token", "/api/v1/cat", "reader", true},
+		{"bearer s3cret-token", "/api/v1/files/ls", "reader", true},
+		{"Bearer s3cret-token", "/api/v1/catx", "reader", false},
+		{"Bearer s3cret-token", "/api/v1/config", "reader", false},
+		{"Bearer s3cret-token", "/api/v1/cat/../config", "reader", false},
+		{"Bearer s3cret-token", "/api/v1/files/./ls", "reader", false},
+		{"Bearer s3cret-token", "/api/v1/files//ls", "reader", false},
+		{"Bearer wrong", "/api/v1/cat", "", false},
+		{basic("admin", "hunter2"), "/api/v1/config", "admin", true},
+		{basic("root", "hunter2"), "/api/v1/config", "", false},
+		{"", "/api/v1/cat", "", false},
+	} {
+		name, ok := api.VerifyAuthorization(tc.header, tc.path)
+		if name != tc.name || ok != tc.ok {
+			t.Fatalf("%q %s: expected (%q, %v), got (%q, %v)", tc.header, tc.path, tc.name, tc.ok, name, ok)
+		}
+	}
+
+	api.Authorizations = map[string]*APIAuthorization{
+		"root": {Scheme: APIAuthBearer, SecretHash: tokenHash, AllowedPaths: []string{"/"}},
+	}
+	if err := api.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := api.VerifyAuthorization("Bearer s3cret-token", "/api/v1/config"); name != "root" || !ok {
+		t.Fatal("expected / to allow every command")
+	}
+
+	api.Authorizations["broken"] = &APIAuthorization{Scheme: APIAuthBearer, SecretHash: "plaintext", AllowedPaths: []string{"/api/v1"}}
+	if err := api.Validate(); err == nil {
+		t.Fatal("expected unhashed secret to be rejected")
+	}
+	api.Authorizations["broken"].SecretHash = tokenHash
+	api.Authorizations["broken"].AllowedPaths = []string{"/api/v1/cat/.."}
+	if err := api.Validate(); err == nil {
+		t.Fatal("expected unclean allowed path to be rejected")
+	}
+}
+
+func TestAPIWarnings(t *testing.T) {
+	c := new(Config)
+	c.Addresses.API = Strings{"/ip4/127.0.0.1/tcp/5001", "/ip6/::1/tcp/5001"}
+	if w := c.Warnings(); len(w) != 0 {
+		t.Fatalf("unexpected warnings: %v", w)
+	}
+
+	c.Addresses.API = Strings{"/ip4/0.0.0.0/tcp/5001"}
+	if w := c.Warnings(); len(w) != 1 {
+		t.Fatalf("expected a warning for a public API without auth, got %v", w)
+	}
+	c.Addresses.RemoteAPI = Strings{"/ip4/0.0.0.0/tcp/5101"}
+	if w := c.Warnings(); len(w) != 2 {
+		t.Fatalf("expected a warning for a public RemoteAPI without a policy, got %v", w)
+	}
+	c.RemoteAPI.Roles = map[string]*RemoteAPIRole{"ops": {Peers: []string{testPeerA}, Commands: []string{"*"}}}
+	if w := c.Warnings(); len(w) != 2 {
+		t.Fatalf("expected roles alone not to restrict the RemoteAPI, got %v", w)
+	}
+	c.RemoteAPI.AllowedPeers = []string{testPeerA}
+	if w := c.Warnings(); len(w) != 1 {
+		t.Fatalf("expected AllowedPeers to silence the RemoteAPI warning, got %v", w)
+	}
+
+	hash, err := HashAPISecret("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.API.Authorizations = map[string]*APIAuthorization{
+		"ops": {Scheme: APIAuthBearer, SecretHash: hash, AllowedPaths: []string{"/api/v1"}},
+	}
+	if w := c.Warnings(); len(w) != 0 {
+		t.Fatalf("unexpected warnings with auth configured: %v", w)
+	}
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"sort"
+	"time"
 )
 
 // AutoNATServiceMode configures the ipfs node's AutoNAT service.
@@ -20,33 +22,55 @@ const (
 	// AutoNATServiceDisabled indicates that the user has disabled the
 	// AutoNATService.
 	AutoNATServiceDisabled
+	// AutoNATServiceAuto indicates that the AutoNATService follows the
+	// Routing.Type: it is enabled for DHT servers and disabled otherwise.
+	AutoNATServiceAuto
 )
 
+// autoNATServiceModes maps each settable mode to its name in the config file.
+var autoNATServiceModes = map[AutoNATServiceMode]string{
+	AutoNATServiceEnabled:  "enabled",
+	AutoNATServiceDisabled: "disabled",
+	AutoNATServiceAuto:     "auto",
+}
+
 func (m *AutoNATServiceMode) UnmarshalText(text []byte) error {
-	switch string(text) {
-	case "":
+	if len(text) == 0 {
 		*m = AutoNATServiceUnset
-	case "enabled":
-		*m = AutoNATServiceEnabled
-	case "disabled":
-		*m = AutoNATServiceDisabled
-	default:
-		return fmt.Errorf("unknown autonat mode: %s", string(text))
+		return nil
 	}
-	return nil
+	for mode, name := range autoNATServiceModes {
+		if name == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown autonat mode: %s", string(text))
 }
 
 func (m AutoNATServiceMode) MarshalText() ([]byte, error) {
-	switch m {
-	case AutoNATServiceUnset:
+	if m == AutoNATServiceUnset {
 		return nil, nil
-	case AutoNATServiceEnabled:
-		return []byte("enabled"), nil
-	case AutoNATServiceDisabled:
-		return []byte("disabled"), nil
-	default:
+	}
+	name, ok := autoNATServiceModes[m]
+	if !ok {
 		return nil, fmt.Errorf("unknown autonat mode: %d", m)
 	}
+	return []byte(name), nil
+}
+
+// WithRouting resolves AutoNATServiceAuto and AutoNATServiceUnset against the
+// given Routing.Type. Other modes are returned unchanged.
+func (m AutoNATServiceMode) WithRouting(routingType string) AutoNATServiceMode {
+	if m != AutoNATServiceAuto && m != AutoNATServiceUnset {
+		return m
+	}
+	switch routingType {
+	case "dht", "dhtserver":
+		return AutoNATServiceEnabled
+	default:
+		return AutoNATServiceDisabled
+	}
 }
 
 // AutoNATConfig configures the node's AutoNAT subsystem.
@@ -62,6 +86,14 @@ type AutoNATConfig struct {
 	// By default, the limits will be a total of 30 dialbacks, with a
 	// per-peer max of 3 peer, resetting every minute.
 	Throttle *AutoNATThrottleConfig `json:",omitempty"`
+
+	// ThrottlePreset selects a named set of throttle limits, one of
+	// "conservative", "helpful" or "public-infra". An explicit Throttle
+	// takes precedence over the preset.
+	ThrottlePreset AutoNATThrottlePreset `json:",omitempty"`
+
+	// V2 configures the AutoNAT v2 protocol.
+	V2 AutoNATV2Config
 }
 
 // AutoNATThrottleConfig configures the throttle limites
@@ -79,3 +111,130 @@ type AutoNATThrottleConfig struct {
 	// When unset, this defaults to 1 minute.
 	Interval Duration `json:",omitempty"`
 }
+
+// AutoNATThrottlePreset names a predefined AutoNATThrottleConfig.
+type AutoNATThrottlePreset string
+
+const (
+	// AutoNATThrottleConservative matches the libp2p defaults.
+	AutoNATThrottleConservative AutoNATThrottlePreset = "conservative"
+	// AutoNATThrottleHelpful raises the limits for well connected nodes.
+	AutoNATThrottleHelpful AutoNATThrottlePreset = "helpful"
+	// AutoNATThrottlePublicInfra is meant for dedicated infrastructure
+	// nodes such as bootstrappers.
+	AutoNATThrottlePublicInfra AutoNATThrottlePreset = "public-infra"
+)
+
+var autoNATThrottlePresets = map[AutoNATThrottlePreset]AutoNATThrottleConfig{
+	AutoNATThrottleConservative: {GlobalLimit: 30, PeerLimit: 3, Interval: Duration(time.Minute)},
+	AutoNATThrottleHelpful:      {GlobalLimit: 120, PeerLimit: 8, Interval: Duration(time.Minute)},
+	AutoNATThrottlePublicInfra:  {GlobalLimit: 600, PeerLimit: 20, Interval: Duration(time.Minute)},
+}
+
+// AutoNATThrottlePresets returns the names of the known throttle presets.
+func AutoNATThrottlePresets() []string {
+	names := make([]string, 0, len(autoNATThrottlePresets))
+	for name := range autoNATThrottlePresets {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *AutoNATThrottlePreset) UnmarshalText(text []byte) error {
+	preset := AutoNATThrottlePreset(text)
+	if _, ok := autoNATThrottlePresets[preset]; !ok && preset != "" {
+		return fmt.Errorf("unknown autonat throttle preset: %s", string(text))
+	}
+	*p = preset
+	return nil
+}
+
+// Limits expands the preset into concrete throttle limits. It returns nil
+// for the empty preset.
+func (p AutoNATThrottlePreset) Limits() (*AutoNATThrottleConfig, error) {
+	if p == "" {
+		return nil, nil
+	}
+	limits, ok := autoNATThrottlePresets[p]
+	if !ok {
+		return nil, fmt.Errorf("unknown autonat throttle preset: %s", string(p))
+	}
+	return &limits, nil
+}
+
+// ThrottleLimits returns the throttle limits in effect: the explicit Throttle
+// if set, otherwise the expanded ThrottlePreset. It returns nil when neither
+// is set and the libp2p defaults apply.
+func (c *AutoNATConfig) ThrottleLimits() (*AutoNATThrottleConfig, error) {
+	if c.Throttle != nil {
+		return c.Throttle, nil
+	}
+	return c.ThrottlePreset.Limits()
+}
+
+// AutoNATV2Config configures the AutoNAT v2 protocol, which tests the
+// reachability of individual addresses rather than of the node as a whole.
+type AutoNATV2Config struct {
+	// Server enables the AutoNAT v2 server. Defaults to on when the
+	// AutoNAT service is enabled.
+	Server Flag `json:",omitempty"`
+
+	// Client enables the AutoNAT v2 client. Defaults to on.
+	Client Flag `json:",omitempty"`
+
+	// DialBack restricts the addresses the server is willing to dial back.
+	DialBack AutoNATDialBackConfig
+}
+
+// AutoNATDialBackConfig restricts AutoNAT v2 dial-backs.
+type AutoNATDialBackConfig struct {
+	// AllowPrivateAddrs permits dialing back private and local addresses.
+	// Only useful on private networks.
+	AllowPrivateAddrs bool `json:",omitempty"`
+
+	// Protocols restricts dial-backs to the listed transport protocols,
+	// e.g. `["tcp", "quic"]`, named as in multiaddrs. All transports are
+	// allowed when empty.
+	Protocols []string `json:",omitempty"`
+
+	// DenyAddrs lists multiaddr CIDRs, e.g. `/ip4/10.0.0.0/ipcidr/8`, that
+	// are never dialed back.
+	DenyAddrs []string `json:",omitempty"`
+}
+
+// ServerEnabled reports whether the AutoNAT v2 server should run for the
+// given Routing.Type.
+func (c *AutoNATConfig) ServerEnabled(routingType string) bool {
+	mode := c.ServiceMode.WithRouting(routingType)
+	return c.V2.Server.WithDefault(mode == AutoNATServiceEnabled)
+}
+
+// Validate checks the AutoNAT settings for invalid values and contradictory
+// combinations.
+func (c *AutoNATConfig) Validate() error {
+	if _, ok := autoNATServiceModes[c.ServiceMode]; !ok && c.ServiceMode != AutoNATServiceUnset {
+		return fmt.Errorf("unknown autonat mode: %d", c.ServiceMode)
+	}
+	if c.ServiceMode == AutoNATServiceDisabled && c.V2.Server == True {
+		return fmt.Errorf("V2.Server is enabled but ServiceMode is disabled")
+	}
+	limits, err := c.ThrottleLimits()
+	if err != nil {
+		return err
+	}
+	if limits != nil && (limits.GlobalLimit < 0 || limits.PeerLimit < 0 || limits.Interval < 0) {
+		return fmt.Errorf("throttle limits must not be negative")
+	}
+	for _, name := range c.V2.DialBack.Protocols {
+		if err := validateProtocolName(name); err != nil {
+			return fmt.Errorf("V2.DialBack.Protocols: %s", err)
+		}
+	}
+	for _, addr := range c.V2.DialBack.DenyAddrs {
+		if _, err := parseIPCIDR(addr); err != nil {
+			return fmt.Errorf("V2.DialBack.DenyAddrs: %s", err)
+		}
+	}
+	return nil
+}
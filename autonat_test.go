@@ -0,0 +1,102 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAutoNATServiceMode(t *testing.T) {
+	for _, name := range []string{"enabled", "disabled", "auto"} {
+		var m AutoNATServiceMode
+		if err := m.UnmarshalText([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+		out, err := m.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != name {
+			t.Fatalf("expected %s, got %s", name, out)
+		}
+	}
+
+	var m AutoNATServiceMode
+	if err := m.UnmarshalText([]byte("sometimes")); err == nil {
+		t.Fatal("expected unknown mode to be rejected")
+	}
+
+	if AutoNATServiceAuto.WithRouting("dht") != AutoNATServiceEnabled {
+		t.Fatal("expected auto mode to be enabled for dht routing")
+	}
+	if AutoNATServiceAuto.WithRouting("dhtclient") != AutoNATServiceDisabled {
+		t.Fatal("expected auto mode to be disabled for dhtclient routing")
+	}
+	if AutoNATServiceUnset.WithRouting("dhtserver") != AutoNATServiceEnabled ||
+		AutoNATServiceUnset.WithRouting("dhtclient") != AutoNATServiceDisabled {
+		t.Fatal("expected unset mode to follow the routing type like auto")
+	}
+	if AutoNATServiceDisabled.WithRouting("dht") != AutoNATServiceDisabled {
+		t.Fatal("expected explicit mode to ignore routing")
+	}
+}
+
+func TestAutoNATThrottlePreset(t *testing.T) {
+	var c AutoNATConfig
+	if err := json.Unmarshal([]byte(`{"ThrottlePreset":"helpful"}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	limits, err := c.ThrottleLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits == nil || limits.GlobalLimit != 120 || limits.PeerLimit != 8 {
+		t.Fatalf("unexpected limits: %+v", limits)
+	}
+
+	c.Throttle = &AutoNATThrottleConfig{GlobalLimit: 5, PeerLimit: 1}
+	limits, err = c.ThrottleLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limits.GlobalLimit != 5 {
+		t.Fatal("expected explicit throttle to take precedence over the preset")
+	}
+
+	if err := json.Unmarshal([]byte(`{"ThrottlePreset":"generous"}`), &c); err == nil {
+		t.Fatal("expected unknown preset to be rejected")
+	}
+}
+
+func TestAutoNATValidate(t *testing.T) {
+	c := AutoNATConfig{ServiceMode: AutoNATServiceAuto}
+	c.V2.DialBack.DenyAddrs = []string{"/ip4/10.0.0.0/ipcidr/8", "/ip6/fc00::/ipcidr/7"}
+	c.V2.DialBack.Protocols = []string{"tcp", "quic"}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.ServerEnabled("dhtserver") || c.ServerEnabled("dhtclient") {
+		t.Fatal("expected v2 server to follow the routing type")
+	}
+	if unset := (AutoNATConfig{}); !unset.ServerEnabled("dhtserver") {
+		t.Fatal("expected v2 server to run on DHT servers when the mode is unset")
+	}
+
+	for _, protocols := range [][]string{{""}, {"tcp", "tpc"}, {"/tcp"}} {
+		c.V2.DialBack.Protocols = protocols
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected protocols %q to be rejected", protocols)
+		}
+	}
+	c.V2.DialBack.Protocols = nil
+
+	c.V2.DialBack.DenyAddrs = []string{"/ip4/10.0.0.0/tcp/8"}
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected invalid CIDR to be rejected")
+	}
+
+	c = AutoNATConfig{ServiceMode: AutoNATServiceDisabled}
+	c.V2.Server = True
+	if err := c.Validate(); err == nil {
+		t.Fatal("expected enabled v2 server with disabled service to be rejected")
+	}
+}
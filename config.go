@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	"github.com/mitchellh/go-homedir"
@@ -31,6 +32,14 @@ type Config struct {
 
 	Services Services // External service domains and info
 
+	RemoteAPI RemoteAPIPolicy // access control for the libp2p-mounted API
+
+	StorageHost   StorageHost   // storage host pricing and contract limits
+	StorageClient StorageClient // storage renter policy
+	HostsSync     HostsSync     // hosts sync options per hub mode
+	Repair        StorageWorker // storage repair jobs
+	Challenge     StorageWorker // storage challenge jobs
+
 	Provider     Provider
 	Reprovider   Reprovider
 	Experimental Experiments
@@ -133,3 +142,122 @@ func (c *Config) Clone() (*Config, error) {
 
 	return &newConfig, nil
 }
+
+// unset reports whether a config section, passed by pointer, is still at its
+// zero value. Validate skips unset sections of the storage features, so that
+// configs created before a section existed stay valid; nothing fills in their
+// defaults.
+func unset(section interface{}) bool {
+	return reflect.ValueOf(section).Elem().IsZero()
+}
+
+// Validate checks the config for invalid values and contradictory settings.
+func (c *Config) Validate() error {
+	if err := c.Swarm.Validate(); err != nil {
+		return fmt.Errorf("Swarm: %s", err)
+	}
+	if err := c.Swarm.NAT.Validate(c.Addresses.Swarm); err != nil {
+		return fmt.Errorf("Swarm.NAT: %s", err)
+	}
+	if err := c.Swarm.ConnectionGater.Validate(); err != nil {
+		return fmt.Errorf("Swarm.ConnectionGater: %s", err)
+	}
+	if err := c.AutoNAT.Validate(); err != nil {
+		return fmt.Errorf("AutoNAT: %s", err)
+	}
+	if err := c.API.Validate(); err != nil {
+		return fmt.Errorf("API: %s", err)
+	}
+	if _, err := NewRemoteAPIAuthorizer(c); err != nil {
+		return fmt.Errorf("RemoteAPI: %s", err)
+	}
+	if err := c.Gateway.Validate(); err != nil {
+		return fmt.Errorf("Gateway: %s", err)
+	}
+	if c.Gateway.TLS.Enabled() != (len(c.Addresses.GatewayHTTPS) > 0) {
+		return fmt.Errorf("Addresses.GatewayHTTPS and Gateway.TLS must be configured together")
+	}
+	if err := c.Datastore.Validate(); err != nil {
+		return fmt.Errorf("Datastore: %s", err)
+	}
+	if err := c.Services.Validate(); err != nil {
+		return fmt.Errorf("Services: %s", err)
+	}
+	if !unset(&c.StorageHost) {
+		if err := c.StorageHost.Validate(); err != nil {
+			return fmt.Errorf("StorageHost: %s", err)
+		}
+	}
+	if err := c.validateCapacity(); err != nil {
+		return err
+	}
+	if !unset(&c.StorageClient) {
+		if err := c.StorageClient.Validate(); err != nil {
+			return fmt.Errorf("StorageClient: %s", err)
+		}
+	}
+	if err := c.HostsSync.Validate(); err != nil {
+		return fmt.Errorf("HostsSync: %s", err)
+	}
+	if !unset(&c.Repair) {
+		if err := c.Repair.Validate(); err != nil {
+			return fmt.Errorf("Repair: %s", err)
+		}
+	}
+	if !unset(&c.Challenge) {
+		if err := c.Challenge.Validate(); err != nil {
+			return fmt.Errorf("Challenge: %s", err)
+		}
+	}
+	if err := c.Experimental.Validate(); err != nil {
+		return fmt.Errorf("Experimental: %s", err)
+	}
+	if c.Experimental.StorageHostEnabled && c.Datastore.GC != nil && !c.Datastore.GC.Protects(GCPinContract) {
+		return fmt.Errorf("Datastore: GC.Protected must include %q on storage hosts", GCPinContract)
+	}
+	return nil
+}
+
+// Warnings reports settings that are valid but likely unsafe.
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.API.CORS != nil {
+		for _, w := range c.API.CORS.Warnings() {
+			warnings = append(warnings, "API: "+w)
+		}
+	}
+	if c.Gateway.CORS != nil {
+		for _, w := range c.Gateway.CORS.Warnings() {
+			warnings = append(warnings, "Gateway: "+w)
+		}
+	}
+	if c.Swarm.SwarmKey == DefaultSwarmKey && c.Experimental.HostsSyncMode == HostsSyncMode(DefaultHostsSyncModeDev) {
+		warnings = append(warnings, "Experimental: HostsSyncMode TESTNET is only served by the testnet hub")
+	}
+	for _, k := range ignoredCORSHeaders(c.API.HTTPHeaders, c.API.CORS) {
+		warnings = append(warnings, fmt.Sprintf("API: HTTPHeaders[%s] is ignored because CORS is set", k))
+	}
+	for _, k := range ignoredCORSHeaders(c.Gateway.HTTPHeaders, c.Gateway.CORS) {
+		warnings = append(warnings, fmt.Sprintf("Gateway: HTTPHeaders[%s] is ignored because CORS is set", k))
+	}
+	for _, w := range c.Gateway.TLS.Warnings() {
+		warnings = append(warnings, "Gateway: TLS: "+w)
+	}
+	if len(c.API.Authorizations) == 0 {
+		for _, addr := range c.Addresses.API {
+			if !isLocalAddr(addr) {
+				warnings = append(warnings, fmt.Sprintf(
+					"API listens on non-loopback address %s without API.Authorizations; anyone who can reach it has full control of the node", addr))
+			}
+		}
+	}
+	if !c.RemoteAPI.restricted() {
+		for _, addr := range c.Addresses.RemoteAPI {
+			if !isLocalAddr(addr) {
+				warnings = append(warnings, fmt.Sprintf(
+					"RemoteAPI listens on non-loopback address %s without RemoteAPI.AllowedPeers or RemoteAPI.Commands; any peer who can reach it can call every command", addr))
+			}
+		}
+	}
+	return warnings
+}
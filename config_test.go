@@ -4,6 +4,24 @@ import (
 	"testing"
 )
 
+func TestValidateSkipsUnsetSections(t *testing.T) {
+	for name, set := range map[string]func(*Config){
+		"StorageHost":   func(c *Config) { c.StorageHost.MaxShardSize = "1GiB" },
+		"StorageClient": func(c *Config) { c.StorageClient.Budget = 1000 },
+		"Repair":        func(c *Config) { c.Repair.MinReward = 10 },
+		"Challenge":     func(c *Config) { c.Challenge.MinReward = 10 },
+	} {
+		cfg := &Config{Datastore: DefaultDatastoreConfig()}
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("expected unset sections to be skipped, got %s", err)
+		}
+		set(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Fatalf("expected a partial %s section to be validated", name)
+		}
+	}
+}
+
 func TestClone(t *testing.T) {
 	c := new(Config)
 	c.Identity.PeerID = "faketest"
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The CORS response headers rendered from a CORS section.
+const (
+	corsAllowOrigin      = "Access-Control-Allow-Origin"
+	corsAllowMethods     = "Access-Control-Allow-Methods"
+	corsAllowHeaders     = "Access-Control-Allow-Headers"
+	corsExposeHeaders    = "Access-Control-Expose-Headers"
+	corsAllowCredentials = "Access-Control-Allow-Credentials"
+	corsMaxAge           = "Access-Control-Max-Age"
+)
+
+// CORS configures cross-origin resource sharing. When set, the
+// Access-Control-* entries of HTTPHeaders are ignored, see
+// EffectiveHTTPHeaders, and reported by Config.Warnings.
+type CORS struct {
+	// AllowedOrigins lists the origins allowed to make cross-origin
+	// requests: "*" for any origin, an exact origin such as
+	// `https://app.example.com`, or a pattern with a single "*" such as
+	// `https://*.example.com`.
+	AllowedOrigins []string `json:",omitempty"`
+
+	// AllowedMethods lists the allowed request methods.
+	AllowedMethods []string `json:",omitempty"`
+
+	// AllowedHeaders lists the request headers clients may send.
+	AllowedHeaders []string `json:",omitempty"`
+
+	// ExposedHeaders lists the response headers clients may read.
+	ExposedHeaders []string `json:",omitempty"`
+
+	// AllowCredentials allows requests with cookies or HTTP
+	// authentication.
+	AllowCredentials bool `json:",omitempty"`
+
+	// MaxAge specifies how long preflight responses may be cached.
+	MaxAge Duration `json:",omitempty"`
+}
+
+// Headers renders the CORS section as HTTP headers, in the form previously
+// configured through HTTPHeaders. Access-Control-Allow-Origin only carries a
+// single value, so it is only rendered when the same value is valid for every
+// request: "*" without credentials, or a single exact origin. Otherwise it
+// must be set per request from AllowedOrigin.
+func (c *CORS) Headers() map[string][]string {
+	headers := map[string][]string{}
+	if origin, ok := c.staticOrigin(); ok {
+		headers[corsAllowOrigin] = []string{origin}
+	}
+	if len(c.AllowedMethods) > 0 {
+		methods := make([]string, len(c.AllowedMethods))
+		for i, m := range c.AllowedMethods {
+			methods[i] = strings.ToUpper(m)
+		}
+		headers[corsAllowMethods] = methods
+	}
+	if len(c.AllowedHeaders) > 0 {
+		headers[corsAllowHeaders] = c.AllowedHeaders
+	}
+	if len(c.ExposedHeaders) > 0 {
+		headers[corsExposeHeaders] = c.ExposedHeaders
+	}
+	if c.AllowCredentials {
+		headers[corsAllowCredentials] = []string{"true"}
+	}
+	if c.MaxAge > 0 {
+		headers[corsMaxAge] = []string{strconv.FormatInt(int64(time.Duration(c.MaxAge)/time.Second), 10)}
+	}
+	return headers
+}
+
+// staticOrigin returns the Access-Control-Allow-Origin value shared by every
+// request, if there is one.
+func (c *CORS) staticOrigin() (string, bool) {
+	if len(c.AllowedOrigins) != 1 {
+		return "", false
+	}
+	origin := c.AllowedOrigins[0]
+	if origin == "*" {
+		return origin, !c.AllowCredentials
+	}
+	return origin, !strings.Contains(origin, "*")
+}
+
+// AllowedOrigin returns the Access-Control-Allow-Origin value to send for a
+// request with the given Origin header, and false when the origin is not
+// allowed.
+func (c *CORS) AllowedOrigin(origin string) (string, bool) {
+	if !c.AllowsOrigin(origin) {
+		return "", false
+	}
+	if static, ok := c.staticOrigin(); ok {
+		return static, true
+	}
+	return origin, true
+}
+
+// AllowsOrigin reports whether the value of an Origin request header is
+// allowed.
+func (c *CORS) AllowsOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if idx := strings.IndexByte(allowed, '*'); idx >= 0 {
+			prefix, suffix := allowed[:idx], allowed[idx+1:]
+			if len(origin) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Validate checks for malformed origins and methods.
+func (c *CORS) Validate() error {
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" || origin == "null" {
+			continue
+		}
+		if strings.Count(origin, "*") > 1 {
+			return fmt.Errorf("invalid origin pattern %q: only one '*' is supported", origin)
+		}
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("invalid origin %q: must start with http:// or https://", origin)
+		}
+		if strings.HasSuffix(origin, "/") {
+			return fmt.Errorf("invalid origin %q: must not end with '/'", origin)
+		}
+	}
+	for _, m := range c.AllowedMethods {
+		switch strings.ToUpper(m) {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+			http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			return fmt.Errorf("invalid method %q", m)
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("MaxAge must not be negative")
+	}
+	return nil
+}
+
+// Warnings reports dangerous but valid combinations.
+func (c *CORS) Warnings() []string {
+	if !c.AllowCredentials {
+		return nil
+	}
+	var warnings []string
+	for _, origin := range c.AllowedOrigins {
+		switch {
+		case origin == "*":
+			warnings = append(warnings, "CORS allows credentials from any origin ('*'); any website can act on behalf of the user")
+		case origin == "null":
+			warnings = append(warnings, "CORS allows credentials from the 'null' origin, which sandboxed pages and local files can use")
+		case strings.HasPrefix(origin, "http://") && !isLocalOrigin(origin):
+			warnings = append(warnings, fmt.Sprintf("CORS allows credentials from the unencrypted origin %s", origin))
+		case strings.Contains(origin, "*"):
+			warnings = append(warnings, fmt.Sprintf("CORS allows credentials from the origin pattern %s", origin))
+		}
+	}
+	return warnings
+}
+
+func isLocalOrigin(origin string) bool {
+	host := strings.TrimPrefix(origin, "http://")
+	if idx := strings.LastIndexByte(host, ':'); idx >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:idx]
+	}
+	return host == "localhost" || host == "127.0.0.1" || host == "[::1]"
+}
+
+// withCORSHeaders returns a copy of headers with the CORS section, if any,
+// rendered in place of their Access-Control-* entries.
+func withCORSHeaders(headers map[string][]string, cors *CORS) map[string][]string {
+	out := make(map[string][]string, len(headers))
+	for k, v := range headers {
+		if cors != nil && isCORSHeader(k) {
+			continue
+		}
+		out[k] = v
+	}
+	if cors != nil {
+		for k, v := range cors.Headers() {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// ignoredCORSHeaders returns the sorted Access-Control-* keys of headers that
+// the CORS section, if any, overrides.
+func ignoredCORSHeaders(headers map[string][]string, cors *CORS) []string {
+	if cors == nil {
+		return nil
+	}
+	var keys []string
+	for k := range headers {
+		if isCORSHeader(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isCORSHeader(key string) bool {
+	switch http.CanonicalHeaderKey(key) {
+	case corsAllowOrigin, corsAllowMethods, corsAllowHeaders,
+		corsExposeHeaders, corsAllowCredentials, corsMaxAge:
+		return true
+	}
+	return false
+}
+
+// corsFromHTTPHeaders converts the Access-Control-* entries of headers into a
+// CORS section, leaving headers untouched. It returns nil when there are none,
+// or when a value cannot be represented.
+func corsFromHTTPHeaders(headers map[string][]string) *CORS {
+	cors := new(CORS)
+	found := false
+	for key, values := range headers {
+		var list []string
+		for _, v := range values {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					list = append(list, item)
+				}
+			}
+		}
+		switch http.CanonicalHeaderKey(key) {
+		case corsAllowOrigin:
+			cors.AllowedOrigins = list
+		case corsAllowMethods:
+			cors.AllowedMethods = list
+		case corsAllowHeaders:
+			cors.AllowedHeaders = list
+		case corsExposeHeaders:
+			cors.ExposedHeaders = list
+		case corsAllowCredentials:
+			if len(list) != 1 || (list[0] != "true" && list[0] != "false") {
+				return nil
+			}
+			cors.AllowCredentials = list[0] == "true"
+		case corsMaxAge:
+			if len(list) != 1 {
+				return nil
+			}
+			seconds, err := strconv.ParseInt(list[0], 10, 64)
+			if err != nil || seconds < 0 {
+				return nil
+			}
+			cors.MaxAge = Duration(time.Duration(seconds) * time.Second)
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return cors
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSHeaders(t *testing.T) {
+	c := &CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowCredentials: true,
+		MaxAge:           Duration(10 * time.Minute),
+	}
+	expected := map[string][]string{
+		"Access-Control-Allow-Origin":      {"https://app.example.com"},
+		"Access-Control-Allow-Methods":     {"GET", "POST"},
+		"Access-Control-Allow-Credentials": {"true"},
+		"Access-Control-Max-Age":           {"600"},
+	}
+	if h := c.Headers(); !reflect.DeepEqual(h, expected) {
+		t.Fatalf("expected %v, got %v", expected, h)
+	}
+
+	api := API{HTTPHeaders: map[string][]string{
+		"X-Custom":                    {"1"},
+		"Access-Control-Allow-Origin": {"https://old.example.com"},
+	}, CORS: c}
+	h := api.EffectiveHTTPHeaders()
+	if len(h) != 5 || h["X-Custom"][0] != "1" || h["Access-Control-Allow-Origin"][0] != "https://app.example.com" {
+		t.Fatalf("unexpected effective headers: %v", h)
+	}
+	if len(api.HTTPHeaders) != 2 {
+		t.Fatal("EffectiveHTTPHeaders modified HTTPHeaders")
+	}
+	cfg := &Config{API: api}
+	if w := cfg.Warnings(); len(w) != 1 || !strings.Contains(w[0], "Access-Control-Allow-Origin") {
+		t.Fatalf("expected a warning for the ignored header, got %v", w)
+	}
+
+	// several origins or a pattern must be resolved per request
+	c.AllowedOrigins = []string{"https://app.example.com", "https://*.example.org"}
+	c.AllowedMethods = []string{"get"}
+	h = c.Headers()
+	if _, ok := h["Access-Control-Allow-Origin"]; ok {
+		t.Fatalf("expected no static origin for several origins, got %v", h)
+	}
+	if h["Access-Control-Allow-Methods"][0] != "GET" {
+		t.Fatalf("expected methods to be upper-cased, got %v", h)
+	}
+	if origin, ok := c.AllowedOrigin("https://a.example.org"); !ok || origin != "https://a.example.org" {
+		t.Fatalf("expected the request origin to be echoed, got %q", origin)
+	}
+	if _, ok := c.AllowedOrigin("https://evil.example.com"); ok {
+		t.Fatal("expected a foreign origin to be refused")
+	}
+	if _, ok := (&CORS{AllowedOrigins: []string{"*"}, AllowCredentials: true}).Headers()["Access-Control-Allow-Origin"]; ok {
+		t.Fatal("expected no static '*' with credentials")
+	}
+	if origin, _ := (&CORS{AllowedOrigins: []string{"*"}}).AllowedOrigin("https://a.example"); origin != "*" {
+		t.Fatalf("expected '*' without credentials, got %q", origin)
+	}
+}
+
+func TestCORSAllowsOrigin(t *testing.T) {
+	c := &CORS{AllowedOrigins: []string{"https://app.example.com", "https://*.example.org"}}
+	for origin, allowed := range map[string]bool{
+		"https://app.example.com":  true,
+		"https://evil.example.com": false,
+		"https://a.example.org":    true,
+		"https://.example.org":     false,
+		"http://a.example.org":     false,
+	} {
+		if c.AllowsOrigin(origin) != allowed {
+			t.Errorf("origin %s: expected allowed=%v", origin, allowed)
+		}
+	}
+	if !(&CORS{AllowedOrigins: []string{"*"}}).AllowsOrigin("https://any.example") {
+		t.Fatal("expected '*' to allow any origin")
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	for _, c := range []CORS{
+		{AllowedOrigins: []string{"example.com"}},
+		{AllowedOrigins: []string{"https://example.com/"}},
+		{AllowedOrigins: []string{"https://*.*.example.com"}},
+		{AllowedMethods: []string{"FETCH"}},
+		{MaxAge: Duration(-time.Second)},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", c)
+		}
+	}
+
+	c := CORS{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"get", "Post"}, AllowCredentials: true}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Warnings()) != 1 {
+		t.Fatalf("expected a warning for '*' with credentials, got %v", c.Warnings())
+	}
+	c.AllowedOrigins = []string{"http://localhost:3000", "https://app.example.com"}
+	if w := c.Warnings(); len(w) != 0 {
+		t.Fatalf("unexpected warnings: %v", w)
+	}
+}
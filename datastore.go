@@ -13,6 +13,9 @@ type Datastore struct {
 	StorageGCWatermark int64  // in percentage to multiply on StorageMax
 	GCPeriod           string // in ns, us, ms, s, m, h
 
+	// GC refines the garbage collection policy; see GCPolicy.
+	GC *GCPolicy `json:",omitempty"`
+
 	// deprecated fields, use Spec
 	Type   string           `json:",omitempty"`
 	Path   string           `json:",omitempty"`
@@ -21,10 +24,30 @@ type Datastore struct {
 
 	Spec map[string]interface{}
 
+	// Volumes lists the disks a storage host offers space on, see
+	// AdvertisedCapacity. Spec still decides where the datastore is
+	// placed: a mount routes whole key namespaces, so no spec can spread
+	// /blocks over several disks.
+	Volumes []Volume `json:",omitempty"`
+
+	// S3 is the bucket the s3ds profile stores blocks in. It is read when
+	// the profile is applied on init, so it must be set in the config file
+	// passed to init.
+	S3 *DatastoreS3 `json:",omitempty"`
+
 	HashOnRead      bool
 	BloomFilterSize int
 }
 
+// DatastoreS3 locates an S3-compatible bucket.
+type DatastoreS3 struct {
+	Bucket string
+	Region string `json:",omitempty"`
+	// Endpoint is the URL of an S3-compatible service such as MinIO. Empty
+	// for AWS.
+	Endpoint string `json:",omitempty"`
+}
+
 // DataStorePath returns the default data store path given a configuration root
 // (set an empty string to have the default configuration root)
 func DataStorePath(configroot string) (string, error) {
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GCPinCategory names a class of pinned blocks the garbage collector can be
+// told to keep.
+type GCPinCategory string
+
+const (
+	// GCPinContract covers blocks held for active storage contracts.
+	GCPinContract GCPinCategory = "contract"
+	// GCPinUser covers blocks pinned by the user.
+	GCPinUser GCPinCategory = "user"
+)
+
+// GCPolicy refines when and how the garbage collector runs.
+type GCPolicy struct {
+	// HighWatermark is the usage, in percent of StorageMax, above which GC
+	// starts. Defaults to Datastore.StorageGCWatermark.
+	HighWatermark int64 `json:",omitempty"`
+
+	// LowWatermark is the usage, in percent of StorageMax, at which GC
+	// stops. When unset, GC removes all unprotected blocks.
+	LowWatermark int64 `json:",omitempty"`
+
+	// MinFreeDisk starts GC when the disk holding the datastore has less
+	// free space, such as "10GB", regardless of the watermarks.
+	MinFreeDisk string `json:",omitempty"`
+
+	// Protected lists the pin categories GC must never remove.
+	Protected []GCPinCategory `json:",omitempty"`
+
+	// MaxDuration bounds a single GC run. Zero means no limit.
+	MaxDuration Duration `json:",omitempty"`
+
+	// QuietHours lists the local times of day during which GC does not
+	// start.
+	QuietHours []TimeOfDayRange `json:",omitempty"`
+}
+
+// GCThresholds holds the byte thresholds derived from the GC settings.
+type GCThresholds struct {
+	StorageMax uint64
+	// High is the usage above which GC starts.
+	High uint64
+	// Low is the usage at which GC stops.
+	Low uint64
+	// MinFreeDisk is the free disk space below which GC starts.
+	MinFreeDisk uint64
+}
+
+// GCThresholds computes the byte thresholds of the GC policy, falling back
+// to StorageGCWatermark when there is no policy.
+func (d *Datastore) GCThresholds() (GCThresholds, error) {
+	var t GCThresholds
+	storageMax, err := parseByteSize(d.StorageMax)
+	if err != nil {
+		return t, fmt.Errorf("StorageMax: %s", err)
+	}
+	t.StorageMax = storageMax
+
+	high := d.StorageGCWatermark
+	var low int64
+	if d.GC != nil {
+		if d.GC.HighWatermark != 0 {
+			high = d.GC.HighWatermark
+		}
+		low = d.GC.LowWatermark
+		if d.GC.MinFreeDisk != "" {
+			if t.MinFreeDisk, err = parseByteSize(d.GC.MinFreeDisk); err != nil {
+				return t, fmt.Errorf("GC.MinFreeDisk: %s", err)
+			}
+		}
+	}
+	t.High = percentOf(storageMax, high)
+	t.Low = percentOf(storageMax, low)
+	return t, nil
+}
+
+func percentOf(v uint64, percent int64) uint64 {
+	return uint64(float64(v) * float64(percent) / 100)
+}
+
+// Protects reports whether GC must keep blocks of a pin category.
+func (p *GCPolicy) Protects(category GCPinCategory) bool {
+	for _, c := range p.Protected {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// InQuietHours reports whether GC must not start at t.
+func (p *GCPolicy) InQuietHours(t time.Time) bool {
+	for _, q := range p.QuietHours {
+		if q.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGC checks the GC settings against each other and StorageMax.
+func (d *Datastore) validateGC() error {
+	if d.StorageGCWatermark < 0 || d.StorageGCWatermark > 100 {
+		return fmt.Errorf("StorageGCWatermark must be between 0 and 100")
+	}
+	if d.GC == nil {
+		return nil
+	}
+	p := d.GC
+	if p.HighWatermark < 0 || p.HighWatermark > 100 || p.LowWatermark < 0 || p.LowWatermark > 100 {
+		return fmt.Errorf("GC watermarks must be between 0 and 100")
+	}
+	t, err := d.GCThresholds()
+	if err != nil {
+		return err
+	}
+	if t.High == 0 {
+		return fmt.Errorf("GC high watermark must be above 0")
+	}
+	if t.Low >= t.High {
+		return fmt.Errorf("GC.LowWatermark must be below the high watermark")
+	}
+	seen := make(map[GCPinCategory]bool, len(p.Protected))
+	for _, c := range p.Protected {
+		switch c {
+		case GCPinContract, GCPinUser:
+		default:
+			return fmt.Errorf("GC.Protected: unknown pin category %q", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("GC.Protected: duplicate pin category %q", c)
+		}
+		seen[c] = true
+	}
+	if p.MaxDuration < 0 {
+		return fmt.Errorf("GC.MaxDuration must not be negative")
+	}
+	for _, q := range p.QuietHours {
+		if err := q.Validate(); err != nil {
+			return fmt.Errorf("GC.QuietHours: %s", err)
+		}
+	}
+	return nil
+}
+
+// byteUnits maps the lower-cased unit suffixes accepted by parseByteSize to
+// their multipliers.
+var byteUnits = map[string]uint64{
+	"":    1,
+	"b":   1,
+	"kb":  1e3,
+	"mb":  1e6,
+	"gb":  1e9,
+	"tb":  1e12,
+	"pb":  1e15,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+	"pib": 1 << 50,
+}
+
+// parseByteSize parses sizes such as "10GB", "1.5 TiB" or "512", in the
+// decimal and binary units StorageMax accepts.
+func parseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	mult, ok := byteUnits[strings.ToLower(strings.TrimSpace(s[i:]))]
+	if !ok || i == 0 {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	v, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %q", s)
+	}
+	if v*float64(mult) >= math.MaxUint64 {
+		return 0, fmt.Errorf("size too large: %q", s)
+	}
+	return uint64(v * float64(mult)), nil
+}
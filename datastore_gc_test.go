@@ -0,0 +1,116 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	for s, expected := range map[string]uint64{
+		"10GB":    10e9,
+		"1TB":     1e12,
+		"1.5 TiB": 1.5 * (1 << 40),
+		"512":     512,
+		"64kiB":   64 << 10,
+		"2gb":     2e9,
+	} {
+		v, err := parseByteSize(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v != expected {
+			t.Errorf("%s: expected %d, got %d", s, expected, v)
+		}
+	}
+	for _, s := range []string{"", "GB", "10XB", "1.2.3GB"} {
+		if _, err := parseByteSize(s); err == nil {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestGCThresholds(t *testing.T) {
+	d := Datastore{StorageMax: "100GB", StorageGCWatermark: 90}
+	th, err := d.GCThresholds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if th.High != 90e9 || th.Low != 0 {
+		t.Fatalf("unexpected legacy thresholds: %+v", th)
+	}
+
+	d.GC = &GCPolicy{HighWatermark: 80, LowWatermark: 60, MinFreeDisk: "5GB"}
+	th, err = d.GCThresholds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := GCThresholds{StorageMax: 100e9, High: 80e9, Low: 60e9, MinFreeDisk: 5e9}
+	if th != expected {
+		t.Fatalf("expected %+v, got %+v", expected, th)
+	}
+}
+
+func TestGCPolicyValidate(t *testing.T) {
+	for _, p := range []GCPolicy{
+		{HighWatermark: 120},
+		{LowWatermark: 95},
+		{MinFreeDisk: "a lot"},
+		{Protected: []GCPinCategory{"cache"}},
+		{Protected: []GCPinCategory{GCPinUser, GCPinUser}},
+		{MaxDuration: Duration(-time.Minute)},
+		{QuietHours: []TimeOfDayRange{{Start: "22:00", End: "6am"}}},
+		{QuietHours: []TimeOfDayRange{{Start: "22:00", End: "22:00"}}},
+	} {
+		p := p
+		d := Datastore{StorageMax: "100GB", StorageGCWatermark: 90, GC: &p}
+		if err := d.validateGC(); err == nil {
+			t.Errorf("expected %+v to be invalid", p)
+		}
+	}
+
+	d := Datastore{StorageMax: "100GB", StorageGCWatermark: 90, GC: &GCPolicy{
+		LowWatermark: 70,
+		MinFreeDisk:  "10GB",
+		Protected:    []GCPinCategory{GCPinContract},
+		MaxDuration:  Duration(time.Hour),
+		QuietHours:   []TimeOfDayRange{{Start: "22:00", End: "06:00"}},
+	}}
+	if err := d.validateGC(); err != nil {
+		t.Fatal(err)
+	}
+
+	// free disk is about the whole disk, not StorageMax
+	d.GC.MinFreeDisk = "200GB"
+	if err := d.validateGC(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGCPolicyQuietHours(t *testing.T) {
+	p := GCPolicy{QuietHours: []TimeOfDayRange{{Start: "22:00", End: "06:00"}, {Start: "12:00", End: "13:00"}}}
+	for hm, quiet := range map[string]bool{
+		"23:30": true,
+		"02:00": true,
+		"06:00": false,
+		"12:30": true,
+		"18:00": false,
+	} {
+		at, _ := time.Parse("15:04", hm)
+		if p.InQuietHours(at) != quiet {
+			t.Errorf("%s: expected quiet=%v", hm, quiet)
+		}
+	}
+}
+
+func TestStorageHostGCProtection(t *testing.T) {
+	c := &Config{Datastore: DefaultDatastoreConfig()}
+	if err := Profiles["storage-host"].Transform(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Datastore.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Datastore.GC.Protects(GCPinContract) {
+		t.Fatal("expected storage hosts to protect contract blocks")
+	}
+}
@@ -0,0 +1,308 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DatastoreChange classifies a change of Datastore.Spec.
+type DatastoreChange int
+
+const (
+	// DatastoreNoop changes options only; the data can stay as it is.
+	DatastoreNoop DatastoreChange = iota
+	// DatastoreConversion changes the on-disk layout; the data must be
+	// converted by following the steps of the plan.
+	DatastoreConversion
+	// DatastoreUnsupported cannot be applied to an existing repo.
+	DatastoreUnsupported
+)
+
+func (c DatastoreChange) String() string {
+	switch c {
+	case DatastoreNoop:
+		return "noop"
+	case DatastoreConversion:
+		return "conversion"
+	case DatastoreUnsupported:
+		return "unsupported"
+	default:
+		return fmt.Sprintf("unknown datastore change %d", int(c))
+	}
+}
+
+// DatastoreStepOp is an operation of a datastore conversion.
+type DatastoreStepOp string
+
+const (
+	// DatastoreStepCreate creates an empty datastore at Path.
+	DatastoreStepCreate DatastoreStepOp = "create"
+	// DatastoreStepCopy copies the keys routed to Mountpoint by the new
+	// spec from the old datastore into the datastore at Path.
+	DatastoreStepCopy DatastoreStepOp = "copy"
+	// DatastoreStepRemove removes the old datastore at Path.
+	DatastoreStepRemove DatastoreStepOp = "remove"
+	// DatastoreStepRename moves the datastore at Path to Target.
+	DatastoreStepRename DatastoreStepOp = "rename"
+	// DatastoreStepWriteSpec writes the new datastore_spec file.
+	DatastoreStepWriteSpec DatastoreStepOp = "write-spec"
+)
+
+// DatastoreStep is one step of a datastore conversion. Paths are relative to
+// the repo root, as in the spec.
+type DatastoreStep struct {
+	Op         DatastoreStepOp
+	Mountpoint string `json:",omitempty"`
+	Path       string `json:",omitempty"`
+	Target     string `json:",omitempty"`
+	Type       string `json:",omitempty"`
+}
+
+func (s DatastoreStep) String() string {
+	switch s.Op {
+	case DatastoreStepCreate:
+		return fmt.Sprintf("create %s datastore at %s", s.Type, s.Path)
+	case DatastoreStepCopy:
+		return fmt.Sprintf("copy keys under %s into %s", s.Mountpoint, s.Path)
+	case DatastoreStepRemove:
+		return fmt.Sprintf("remove old %s datastore at %s", s.Type, s.Path)
+	case DatastoreStepRename:
+		return fmt.Sprintf("move %s to %s", s.Path, s.Target)
+	case DatastoreStepWriteSpec:
+		return "write " + DatastoreSpecFile
+	default:
+		return string(s.Op)
+	}
+}
+
+// DatastorePlan describes how to get from one Datastore.Spec to another.
+type DatastorePlan struct {
+	Change DatastoreChange
+	Reason string
+	Steps  []DatastoreStep
+}
+
+func (p *DatastorePlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n", p.Change, p.Reason)
+	for i, s := range p.Steps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, s)
+	}
+	return b.String()
+}
+
+// convertibleDatastores lists the leaf datastore types a conversion tool can
+// read from and write to.
+var convertibleDatastores = map[string]bool{
+	"flatfs":   true,
+	"levelds":  true,
+	"badgerds": true,
+	"pebbleds": true,
+}
+
+// datastoreConvertSuffix is appended to the path of a datastore being built
+// during a conversion.
+const datastoreConvertSuffix = ".convert"
+
+// datastoreLeaf is a datastore holding data, with the mountpoint it serves.
+type datastoreLeaf struct {
+	mountpoint string
+	disk       map[string]interface{}
+}
+
+func (l datastoreLeaf) path() string {
+	p, _ := l.disk["path"].(string)
+	return p
+}
+
+func (l datastoreLeaf) typ() string {
+	t, _ := l.disk["type"].(string)
+	return t
+}
+
+// datastoreLeaves flattens a spec into its leaf datastores, sorted by
+// mountpoint. Nested mounts are not supported.
+func datastoreLeaves(spec DatastoreSpec) ([]datastoreLeaf, error) {
+	var leaves []datastoreLeaf
+	if m, ok := spec.(*MountSpec); ok {
+		for _, mp := range m.Mounts {
+			if hasNestedMount(mp.Child) {
+				return nil, fmt.Errorf("nested mount at %s", mp.Mountpoint)
+			}
+			leaves = append(leaves, datastoreLeaf{mp.Mountpoint, mp.Child.DiskSpec()})
+		}
+	} else {
+		if hasNestedMount(spec) {
+			return nil, fmt.Errorf("nested mount")
+		}
+		leaves = append(leaves, datastoreLeaf{"/", spec.DiskSpec()})
+	}
+	sort.Slice(leaves, func(i, j int) bool { return leaves[i].mountpoint < leaves[j].mountpoint })
+	return leaves, nil
+}
+
+func hasNestedMount(spec DatastoreSpec) bool {
+	switch s := spec.(type) {
+	case *MountSpec:
+		return true
+	case *MeasureSpec:
+		return hasNestedMount(s.Child)
+	case *LogSpec:
+		return hasNestedMount(s.Child)
+	default:
+		return false
+	}
+}
+
+// PlanDatastoreChange compares two Datastore.Spec values. Changes that leave
+// the DiskSpec untouched are no-ops. Otherwise every leaf datastore whose
+// layout or set of keys changes is rebuilt next to the old one, filled from
+// the old datastore, and moved into place once the old datastores are
+// removed.
+func PlanDatastoreChange(oldSpec, newSpec map[string]interface{}) *DatastorePlan {
+	unsupported := func(format string, args ...interface{}) *DatastorePlan {
+		return &DatastorePlan{Change: DatastoreUnsupported, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	oldDS, err := ParseDatastoreSpec(oldSpec)
+	if err != nil {
+		return unsupported("current spec: %s", err)
+	}
+	newDS, err := ParseDatastoreSpec(newSpec)
+	if err != nil {
+		return unsupported("new spec: %s", err)
+	}
+	if err := newDS.Validate(); err != nil {
+		return unsupported("new spec: %s", err)
+	}
+	if reflect.DeepEqual(oldDS.DiskSpec(), newDS.DiskSpec()) {
+		return &DatastorePlan{Change: DatastoreNoop, Reason: "the on-disk layout is unchanged"}
+	}
+
+	oldLeaves, err := datastoreLeaves(oldDS)
+	if err != nil {
+		return unsupported("current spec: %s", err)
+	}
+	newLeaves, err := datastoreLeaves(newDS)
+	if err != nil {
+		return unsupported("new spec: %s", err)
+	}
+	for _, l := range append(append([]datastoreLeaf(nil), oldLeaves...), newLeaves...) {
+		if !convertibleDatastores[l.typ()] {
+			return unsupported("%s datastores at %s cannot be converted", l.typ(), l.mountpoint)
+		}
+	}
+
+	paths := make(map[string]bool, len(newLeaves))
+	for _, l := range newLeaves {
+		if paths[l.path()] {
+			return unsupported("new spec: path %s is used by several datastores", l.path())
+		}
+		paths[l.path()] = true
+	}
+
+	oldByMount := make(map[string]datastoreLeaf, len(oldLeaves))
+	for _, l := range oldLeaves {
+		oldByMount[l.mountpoint] = l
+	}
+	newByMount := make(map[string]datastoreLeaf, len(newLeaves))
+	for _, l := range newLeaves {
+		newByMount[l.mountpoint] = l
+	}
+	// mountpoints present in only one of the specs move keys between the
+	// datastores mounted above them
+	var changedMounts []string
+	for mp := range oldByMount {
+		if _, ok := newByMount[mp]; !ok {
+			changedMounts = append(changedMounts, mp)
+		}
+	}
+	for mp := range newByMount {
+		if _, ok := oldByMount[mp]; !ok {
+			changedMounts = append(changedMounts, mp)
+		}
+	}
+
+	kept := make(map[string]bool)
+	var rebuilt []datastoreLeaf
+	for _, l := range newLeaves {
+		old, ok := oldByMount[l.mountpoint]
+		if ok && reflect.DeepEqual(old.disk, l.disk) && !hasMountBelow(l.mountpoint, changedMounts) {
+			kept[l.path()] = true
+			continue
+		}
+		rebuilt = append(rebuilt, l)
+	}
+
+	plan := &DatastorePlan{Change: DatastoreConversion}
+	var reasons []string
+	for _, l := range rebuilt {
+		reasons = append(reasons, fmt.Sprintf("%s becomes %s", l.mountpoint, l.typ()))
+		plan.Steps = append(plan.Steps, DatastoreStep{
+			Op:         DatastoreStepCreate,
+			Mountpoint: l.mountpoint,
+			Path:       l.path() + datastoreConvertSuffix,
+			Type:       l.typ(),
+		})
+	}
+	for _, l := range rebuilt {
+		plan.Steps = append(plan.Steps, DatastoreStep{
+			Op:         DatastoreStepCopy,
+			Mountpoint: l.mountpoint,
+			Path:       l.path() + datastoreConvertSuffix,
+		})
+	}
+	for _, l := range oldLeaves {
+		if kept[l.path()] {
+			continue
+		}
+		plan.Steps = append(plan.Steps, DatastoreStep{
+			Op:         DatastoreStepRemove,
+			Mountpoint: l.mountpoint,
+			Path:       l.path(),
+			Type:       l.typ(),
+		})
+	}
+	for _, l := range rebuilt {
+		plan.Steps = append(plan.Steps, DatastoreStep{
+			Op:     DatastoreStepRename,
+			Path:   l.path() + datastoreConvertSuffix,
+			Target: l.path(),
+		})
+	}
+	plan.Steps = append(plan.Steps, DatastoreStep{Op: DatastoreStepWriteSpec})
+	plan.Reason = "the on-disk layout changes: " + strings.Join(reasons, ", ")
+	return plan
+}
+
+// hasMountBelow reports whether one of mounts is served by mountpoint, i.e.
+// lies below it.
+func hasMountBelow(mountpoint string, mounts []string) bool {
+	prefix := strings.TrimSuffix(mountpoint, "/") + "/"
+	for _, m := range mounts {
+		if strings.HasPrefix(m, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanProfileDatastoreChange applies a profile to a copy of c and plans the
+// resulting change of Datastore.Spec, so callers can explain why an InitOnly
+// profile cannot be applied, or hand the plan to a converter.
+func PlanProfileDatastoreChange(c *Config, profile string) (*DatastorePlan, error) {
+	p, ok := Profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile: %s", profile)
+	}
+	changed, err := c.Clone()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Transform(changed); err != nil {
+		return nil, err
+	}
+	return PlanDatastoreChange(c.Datastore.Spec, changed.Datastore.Spec), nil
+}
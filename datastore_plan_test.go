@@ -0,0 +1,78 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanDatastoreChangeNoop(t *testing.T) {
+	spec := DefaultFlatfsSpec().(*MountSpec)
+	spec.Mounts[0].Child.(*MeasureSpec).Child.(*FlatfsSpec).Sync = false
+	plan := PlanDatastoreChange(flatfsSpec(), spec.SpecMap())
+	if plan.Change != DatastoreNoop || len(plan.Steps) != 0 {
+		t.Fatalf("expected toggling sync to be a no-op, got %s", plan)
+	}
+}
+
+func TestPlanDatastoreChangeConversion(t *testing.T) {
+	plan := PlanDatastoreChange(flatfsSpec(), badgerSpec())
+	if plan.Change != DatastoreConversion {
+		t.Fatalf("expected a conversion, got %s", plan)
+	}
+	expected := []DatastoreStep{
+		{Op: DatastoreStepCreate, Mountpoint: "/", Path: "badgerds.convert", Type: "badgerds"},
+		{Op: DatastoreStepCopy, Mountpoint: "/", Path: "badgerds.convert"},
+		{Op: DatastoreStepRemove, Mountpoint: "/", Path: "datastore", Type: "levelds"},
+		{Op: DatastoreStepRemove, Mountpoint: "/blocks", Path: "blocks", Type: "flatfs"},
+		{Op: DatastoreStepRename, Path: "badgerds.convert", Target: "badgerds"},
+		{Op: DatastoreStepWriteSpec},
+	}
+	if !reflect.DeepEqual(plan.Steps, expected) {
+		t.Fatalf("unexpected steps:\n%s", plan)
+	}
+
+	// re-sharding flatfs only rebuilds the blocks mount
+	spec := DefaultFlatfsSpec().(*MountSpec)
+	spec.Mounts[0].Child.(*MeasureSpec).Child.(*FlatfsSpec).ShardFunc = "/repo/flatfs/shard/v1/prefix/3"
+	plan = PlanDatastoreChange(flatfsSpec(), spec.SpecMap())
+	expected = []DatastoreStep{
+		{Op: DatastoreStepCreate, Mountpoint: "/blocks", Path: "blocks.convert", Type: "flatfs"},
+		{Op: DatastoreStepCopy, Mountpoint: "/blocks", Path: "blocks.convert"},
+		{Op: DatastoreStepRemove, Mountpoint: "/blocks", Path: "blocks", Type: "flatfs"},
+		{Op: DatastoreStepRename, Path: "blocks.convert", Target: "blocks"},
+		{Op: DatastoreStepWriteSpec},
+	}
+	if plan.Change != DatastoreConversion || !reflect.DeepEqual(plan.Steps, expected) {
+		t.Fatalf("unexpected plan:\n%s", plan)
+	}
+}
+
+func TestPlanDatastoreChangeUnsupported(t *testing.T) {
+	nested := &MountSpec{Mounts: []MountPoint{{
+		Mountpoint: "/",
+		Child:      DefaultFlatfsSpec(),
+	}}}
+	for _, newSpec := range []map[string]interface{}{
+		{"type": "rocksdb"},
+		nested.SpecMap(),
+		(&MountSpec{Mounts: []MountPoint{{"/blocks", &LeveldsSpec{Path: "blocks"}}}}).SpecMap(),
+	} {
+		if plan := PlanDatastoreChange(flatfsSpec(), newSpec); plan.Change != DatastoreUnsupported || plan.Reason == "" {
+			t.Errorf("expected an unsupported change, got %s", plan)
+		}
+	}
+}
+
+func TestPlanProfileDatastoreChange(t *testing.T) {
+	c := &Config{Datastore: DefaultDatastoreConfig()}
+	plan, err := PlanProfileDatastoreChange(c, "badgerds")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Change != DatastoreConversion {
+		t.Fatalf("expected a conversion, got %s", plan)
+	}
+	if plan, err = PlanProfileDatastoreChange(c, "flatfs"); err != nil || plan.Change != DatastoreNoop {
+		t.Fatalf("expected a no-op, got %v, %v", plan, err)
+	}
+}
@@ -0,0 +1,612 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DatastoreSpecFile is the name of the file in the repo holding the
+// DiskSpec of the datastore the repo was created with.
+const DatastoreSpecFile = "datastore_spec"
+
+// DatastoreSpec is a node of the typed form of Datastore.Spec.
+type DatastoreSpec interface {
+	// Type returns the value of the "type" key.
+	Type() string
+
+	// SpecMap serializes the node back into its Datastore.Spec form.
+	SpecMap() map[string]interface{}
+
+	// DiskSpec returns the parts of the node that determine the on-disk
+	// layout, in the form stored in the datastore_spec file. Options that
+	// can change without touching the data, such as sync, are left out.
+	DiskSpec() map[string]interface{}
+
+	// Validate checks the node and its children.
+	Validate() error
+}
+
+// MountSpec mounts child datastores at key prefixes.
+type MountSpec struct {
+	Mounts []MountPoint
+}
+
+// MountPoint is a child datastore of a MountSpec.
+type MountPoint struct {
+	Mountpoint string
+	Child      DatastoreSpec
+}
+
+// MeasureSpec records metrics about its child under Prefix.
+type MeasureSpec struct {
+	Prefix string
+	Child  DatastoreSpec
+}
+
+// LogSpec logs the operations on its child under Name.
+type LogSpec struct {
+	Name  string
+	Child DatastoreSpec
+}
+
+// FlatfsSpec stores each key as a file under Path, sharded by ShardFunc.
+type FlatfsSpec struct {
+	Path      string
+	ShardFunc string
+	Sync      bool
+}
+
+// LeveldsSpec is a leveldb datastore.
+type LeveldsSpec struct {
+	Path        string
+	Compression string // "none" or "snappy"
+}
+
+// BadgerdsSpec is a badger datastore.
+type BadgerdsSpec struct {
+	Path         string
+	SyncWrites   bool
+	Truncate     bool
+	VLogFileSize string `json:",omitempty"`
+}
+
+// PebbledsSpec is a pebble datastore.
+type PebbledsSpec struct {
+	Path string
+	// CacheSize is the block cache size in bytes; 0 uses the pebble default.
+	CacheSize int64
+}
+
+// S3dsSpec stores keys as objects in an S3-compatible bucket.
+type S3dsSpec struct {
+	Region         string
+	Bucket         string
+	RootDirectory  string
+	RegionEndpoint string // for S3-compatible services such as MinIO
+	// AccessKey and SecretKey may be left empty to use the credentials of
+	// the environment.
+	AccessKey string
+	SecretKey string
+	Workers   int
+}
+
+// ParseDatastoreSpec parses a Datastore.Spec into its typed form. Unknown
+// types and unknown or mistyped keys are reported as errors.
+func ParseDatastoreSpec(spec map[string]interface{}) (DatastoreSpec, error) {
+	p := &specParser{m: spec}
+	t := p.str("type", true)
+	var ds DatastoreSpec
+	switch t {
+	case "mount":
+		ds = p.mount()
+	case "measure":
+		ds = &MeasureSpec{Prefix: p.str("prefix", true), Child: p.child()}
+	case "log":
+		ds = &LogSpec{Name: p.str("name", true), Child: p.child()}
+	case "flatfs":
+		ds = &FlatfsSpec{Path: p.str("path", true), ShardFunc: p.str("shardFunc", true), Sync: p.bool("sync")}
+	case "levelds":
+		ds = &LeveldsSpec{Path: p.str("path", true), Compression: p.str("compression", false)}
+	case "badgerds":
+		ds = &BadgerdsSpec{
+			Path:         p.str("path", true),
+			SyncWrites:   p.bool("syncWrites"),
+			Truncate:     p.bool("truncate"),
+			VLogFileSize: p.str("vlogFileSize", false),
+		}
+	case "pebbleds":
+		ds = &PebbledsSpec{Path: p.str("path", true), CacheSize: p.int64("cacheSize")}
+	case "s3ds":
+		ds = &S3dsSpec{
+			Region:         p.str("region", false),
+			Bucket:         p.str("bucket", true),
+			RootDirectory:  p.str("rootDirectory", false),
+			RegionEndpoint: p.str("regionEndpoint", false),
+			AccessKey:      p.str("accessKey", false),
+			SecretKey:      p.str("secretKey", false),
+			Workers:        int(p.int64("workers")),
+		}
+	case "":
+		return nil, fmt.Errorf("datastore spec without type")
+	default:
+		return nil, fmt.Errorf("unknown datastore type: %s", t)
+	}
+	if p.err != nil {
+		return nil, fmt.Errorf("%s: %s", t, p.err)
+	}
+	if err := p.unknownKeys(); err != nil {
+		return nil, fmt.Errorf("%s: %s", t, err)
+	}
+	return ds, nil
+}
+
+// specParser reads keys of a raw spec node, remembering the first error and
+// the keys it has seen.
+type specParser struct {
+	m    map[string]interface{}
+	seen []string
+	err  error
+}
+
+func (p *specParser) get(key string) (interface{}, bool) {
+	p.seen = append(p.seen, key)
+	v, ok := p.m[key]
+	return v, ok
+}
+
+func (p *specParser) fail(format string, args ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (p *specParser) str(key string, required bool) string {
+	v, ok := p.get(key)
+	if !ok {
+		if required {
+			p.fail("missing %q", key)
+		}
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		p.fail("%q must be a string", key)
+	}
+	return s
+}
+
+func (p *specParser) bool(key string) bool {
+	v, ok := p.get(key)
+	if !ok {
+		return false
+	}
+	b, ok := v.(bool)
+	if !ok {
+		p.fail("%q must be a boolean", key)
+	}
+	return b
+}
+
+func (p *specParser) int64(key string) int64 {
+	v, ok := p.get(key)
+	if !ok {
+		return 0
+	}
+	switch n := v.(type) {
+	case float64:
+		if n == float64(int64(n)) {
+			return int64(n)
+		}
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	}
+	p.fail("%q must be an integer", key)
+	return 0
+}
+
+func (p *specParser) child() DatastoreSpec {
+	return p.spec("child")
+}
+
+func (p *specParser) spec(key string) DatastoreSpec {
+	v, ok := p.get(key)
+	if !ok {
+		p.fail("missing %q", key)
+		return nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		p.fail("%q must be an object", key)
+		return nil
+	}
+	ds, err := ParseDatastoreSpec(m)
+	if err != nil {
+		p.fail("%s: %s", key, err)
+	}
+	return ds
+}
+
+func (p *specParser) mount() DatastoreSpec {
+	v, ok := p.get("mounts")
+	list, isList := v.([]interface{})
+	if !ok || !isList {
+		p.fail("%q must be a list", "mounts")
+		return nil
+	}
+	ms := &MountSpec{}
+	for i, item := range list {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			p.fail("mount %d must be an object", i)
+			return nil
+		}
+		mp, ok := m["mountpoint"].(string)
+		if !ok {
+			p.fail("mount %d: missing %q", i, "mountpoint")
+			return nil
+		}
+		child := make(map[string]interface{}, len(m)-1)
+		for k, v := range m {
+			if k != "mountpoint" {
+				child[k] = v
+			}
+		}
+		ds, err := ParseDatastoreSpec(child)
+		if err != nil {
+			p.fail("mount %s: %s", mp, err)
+			return nil
+		}
+		ms.Mounts = append(ms.Mounts, MountPoint{Mountpoint: mp, Child: ds})
+	}
+	return ms
+}
+
+func (p *specParser) unknownKeys() error {
+	var unknown []string
+	for k := range p.m {
+		if k == "type" {
+			continue
+		}
+		found := false
+		for _, s := range p.seen {
+			if s == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func (s *MountSpec) Type() string { return "mount" }
+
+func (s *MountSpec) SpecMap() map[string]interface{} {
+	mounts := make([]interface{}, 0, len(s.Mounts))
+	for _, m := range s.Mounts {
+		child := m.Child.SpecMap()
+		child["mountpoint"] = m.Mountpoint
+		mounts = append(mounts, child)
+	}
+	return map[string]interface{}{"type": "mount", "mounts": mounts}
+}
+
+// DiskSpec lists the mounts by descending mountpoint, the order in which
+// they are matched.
+func (s *MountSpec) DiskSpec() map[string]interface{} {
+	sorted := append([]MountPoint(nil), s.Mounts...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Mountpoint > sorted[j].Mountpoint
+	})
+	mounts := make([]interface{}, 0, len(sorted))
+	for _, m := range sorted {
+		child := m.Child.DiskSpec()
+		child["mountpoint"] = m.Mountpoint
+		mounts = append(mounts, child)
+	}
+	return map[string]interface{}{"type": "mount", "mounts": mounts}
+}
+
+// Validate checks that every mountpoint is a clean absolute path, that no
+// two mounts share a mountpoint and that "/" is mounted.
+func (s *MountSpec) Validate() error {
+	seen := make(map[string]bool, len(s.Mounts))
+	for _, m := range s.Mounts {
+		if !strings.HasPrefix(m.Mountpoint, "/") {
+			return fmt.Errorf("mountpoint %q must start with '/'", m.Mountpoint)
+		}
+		clean := path.Clean(m.Mountpoint)
+		if seen[clean] {
+			return fmt.Errorf("mountpoint %q overlaps another mount", m.Mountpoint)
+		}
+		seen[clean] = true
+		if err := m.Child.Validate(); err != nil {
+			return fmt.Errorf("mount %s: %s", m.Mountpoint, err)
+		}
+	}
+	if !seen["/"] {
+		return fmt.Errorf("no datastore mounted at \"/\"")
+	}
+	return nil
+}
+
+func (s *MeasureSpec) Type() string { return "measure" }
+
+func (s *MeasureSpec) SpecMap() map[string]interface{} {
+	return map[string]interface{}{"type": "measure", "prefix": s.Prefix, "child": s.Child.SpecMap()}
+}
+
+func (s *MeasureSpec) DiskSpec() map[string]interface{} { return s.Child.DiskSpec() }
+
+func (s *MeasureSpec) Validate() error {
+	if s.Prefix == "" {
+		return fmt.Errorf("measure: missing prefix")
+	}
+	return s.Child.Validate()
+}
+
+func (s *LogSpec) Type() string { return "log" }
+
+func (s *LogSpec) SpecMap() map[string]interface{} {
+	return map[string]interface{}{"type": "log", "name": s.Name, "child": s.Child.SpecMap()}
+}
+
+func (s *LogSpec) DiskSpec() map[string]interface{} { return s.Child.DiskSpec() }
+
+func (s *LogSpec) Validate() error {
+	if s.Name == "" {
+		return fmt.Errorf("log: missing name")
+	}
+	return s.Child.Validate()
+}
+
+func (s *FlatfsSpec) Type() string { return "flatfs" }
+
+func (s *FlatfsSpec) SpecMap() map[string]interface{} {
+	return map[string]interface{}{"type": "flatfs", "path": s.Path, "shardFunc": s.ShardFunc, "sync": s.Sync}
+}
+
+func (s *FlatfsSpec) DiskSpec() map[string]interface{} {
+	return map[string]interface{}{"type": "flatfs", "path": s.Path, "shardFunc": s.ShardFunc}
+}
+
+func (s *FlatfsSpec) Validate() error {
+	if s.Path == "" {
+		return fmt.Errorf("flatfs: missing path")
+	}
+	if err := validateShardFunc(s.ShardFunc); err != nil {
+		return fmt.Errorf("flatfs: %s", err)
+	}
+	return nil
+}
+
+// validateShardFunc checks a flatfs shard function identifier of the form
+// `/repo/flatfs/shard/v1/<prefix|suffix|next-to-last>/<length>`.
+func validateShardFunc(s string) error {
+	parts := strings.Split(s, "/")
+	if len(parts) != 7 || parts[0] != "" || parts[1] != "repo" || parts[2] != "flatfs" ||
+		parts[3] != "shard" || parts[4] != "v1" {
+		return fmt.Errorf("invalid shard function %q", s)
+	}
+	switch parts[5] {
+	case "prefix", "suffix", "next-to-last":
+	default:
+		return fmt.Errorf("invalid shard function %q: unknown function %s", s, parts[5])
+	}
+	if n, err := strconv.Atoi(parts[6]); err != nil || n <= 0 {
+		return fmt.Errorf("invalid shard function %q: invalid length %s", s, parts[6])
+	}
+	return nil
+}
+
+func (s *LeveldsSpec) Type() string { return "levelds" }
+
+func (s *LeveldsSpec) SpecMap() map[string]interface{} {
+	m := map[string]interface{}{"type": "levelds", "path": s.Path}
+	if s.Compression != "" {
+		m["compression"] = s.Compression
+	}
+	return m
+}
+
+func (s *LeveldsSpec) DiskSpec() map[string]interface{} {
+	return map[string]interface{}{"type": "levelds", "path": s.Path}
+}
+
+func (s *LeveldsSpec) Validate() error {
+	if s.Path == "" {
+		return fmt.Errorf("levelds: missing path")
+	}
+	switch s.Compression {
+	case "", "none", "snappy":
+	default:
+		return fmt.Errorf("levelds: invalid compression %q", s.Compression)
+	}
+	return nil
+}
+
+func (s *BadgerdsSpec) Type() string { return "badgerds" }
+
+func (s *BadgerdsSpec) SpecMap() map[string]interface{} {
+	m := map[string]interface{}{"type": "badgerds", "path": s.Path, "syncWrites": s.SyncWrites, "truncate": s.Truncate}
+	if s.VLogFileSize != "" {
+		m["vlogFileSize"] = s.VLogFileSize
+	}
+	return m
+}
+
+func (s *BadgerdsSpec) DiskSpec() map[string]interface{} {
+	return map[string]interface{}{"type": "badgerds", "path": s.Path}
+}
+
+func (s *BadgerdsSpec) Validate() error {
+	if s.Path == "" {
+		return fmt.Errorf("badgerds: missing path")
+	}
+	return nil
+}
+
+func (s *PebbledsSpec) Type() string { return "pebbleds" }
+
+func (s *PebbledsSpec) SpecMap() map[string]interface{} {
+	m := map[string]interface{}{"type": "pebbleds", "path": s.Path}
+	if s.CacheSize != 0 {
+		m["cacheSize"] = s.CacheSize
+	}
+	return m
+}
+
+func (s *PebbledsSpec) DiskSpec() map[string]interface{} {
+	return map[string]interface{}{"type": "pebbleds", "path": s.Path}
+}
+
+func (s *PebbledsSpec) Validate() error {
+	if s.Path == "" {
+		return fmt.Errorf("pebbleds: missing path")
+	}
+	if s.CacheSize < 0 {
+		return fmt.Errorf("pebbleds: cacheSize must not be negative")
+	}
+	return nil
+}
+
+func (s *S3dsSpec) Type() string { return "s3ds" }
+
+func (s *S3dsSpec) SpecMap() map[string]interface{} {
+	m := map[string]interface{}{"type": "s3ds", "bucket": s.Bucket}
+	for k, v := range map[string]string{
+		"region":         s.Region,
+		"rootDirectory":  s.RootDirectory,
+		"regionEndpoint": s.RegionEndpoint,
+		"accessKey":      s.AccessKey,
+		"secretKey":      s.SecretKey,
+	} {
+		if v != "" {
+			m[k] = v
+		}
+	}
+	if s.Workers != 0 {
+		m["workers"] = s.Workers
+	}
+	return m
+}
+
+func (s *S3dsSpec) DiskSpec() map[string]interface{} {
+	return map[string]interface{}{
+		"type":          "s3ds",
+		"region":        s.Region,
+		"bucket":        s.Bucket,
+		"rootDirectory": s.RootDirectory,
+	}
+}
+
+// Validate checks the bucket name and endpoint. Use CheckBucket to check
+// that the bucket can be reached.
+func (s *S3dsSpec) Validate() error {
+	if err := validateBucketName(s.Bucket); err != nil {
+		return fmt.Errorf("s3ds: %s", err)
+	}
+	if s.Region == "" && s.RegionEndpoint == "" {
+		return fmt.Errorf("s3ds: region or regionEndpoint must be set")
+	}
+	if s.RegionEndpoint != "" {
+		u, err := url.Parse(s.RegionEndpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("s3ds: invalid regionEndpoint %q", s.RegionEndpoint)
+		}
+	}
+	if (s.AccessKey == "") != (s.SecretKey == "") {
+		return fmt.Errorf("s3ds: accessKey and secretKey must be set together")
+	}
+	if s.Workers < 0 {
+		return fmt.Errorf("s3ds: workers must not be negative")
+	}
+	return nil
+}
+
+// validateBucketName checks the S3 bucket naming rules.
+func validateBucketName(name string) error {
+	if len(name) < 3 || len(name) > 63 {
+		return fmt.Errorf("bucket name %q must be 3 to 63 characters long", name)
+	}
+	for i, r := range name {
+		alnum := (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+		if !alnum && ((r != '-' && r != '.') || i == 0 || i == len(name)-1) {
+			return fmt.Errorf("invalid bucket name %q", name)
+		}
+	}
+	if strings.Contains(name, "..") {
+		return fmt.Errorf("invalid bucket name %q", name)
+	}
+	return nil
+}
+
+// Endpoint returns the URL of the S3 service.
+func (s *S3dsSpec) Endpoint() string {
+	if s.RegionEndpoint != "" {
+		return strings.TrimSuffix(s.RegionEndpoint, "/")
+	}
+	return fmt.Sprintf("https://s3.%s.amazonaws.com", s.Region)
+}
+
+// CheckBucket checks that the bucket exists by sending an unsigned HEAD
+// request for it, path-style. A 403 response means the bucket exists but
+// requires the credentials the datastore signs its requests with.
+func (s *S3dsSpec) CheckBucket(client *http.Client) error {
+	resp, err := client.Head(s.Endpoint() + "/" + s.Bucket)
+	if err != nil {
+		return fmt.Errorf("s3ds: %s", err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusForbidden:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("s3ds: bucket %s not found at %s", s.Bucket, s.Endpoint())
+	default:
+		return fmt.Errorf("s3ds: unexpected response from %s: %s", s.Endpoint(), resp.Status)
+	}
+}
+
+// DiskSpecBytes returns the contents of the datastore_spec file for a spec.
+// The daemon compares them with the file to detect a changed layout.
+func DiskSpecBytes(spec DatastoreSpec) ([]byte, error) {
+	return json.Marshal(spec.DiskSpec())
+}
+
+// TypedSpec parses Spec into its typed form.
+func (d *Datastore) TypedSpec() (DatastoreSpec, error) {
+	return ParseDatastoreSpec(d.Spec)
+}
+
+// Validate checks the GC settings, the volumes and Spec.
+func (d *Datastore) Validate() error {
+	if err := d.validateGC(); err != nil {
+		return err
+	}
+	if err := d.validateVolumes(); err != nil {
+		return fmt.Errorf("Volumes: %s", err)
+	}
+	spec, err := d.TypedSpec()
+	if err != nil {
+		return fmt.Errorf("Spec: %s", err)
+	}
+	if err := spec.Validate(); err != nil {
+		return fmt.Errorf("Spec: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTripSpec returns spec as it reads back from a config file.
+func roundTripSpec(t *testing.T, spec map[string]interface{}) map[string]interface{} {
+	b, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestParseDatastoreSpec(t *testing.T) {
+	for _, raw := range []map[string]interface{}{flatfsSpec(), badgerSpec()} {
+		raw = roundTripSpec(t, raw)
+		spec, err := ParseDatastoreSpec(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := spec.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if out := roundTripSpec(t, spec.SpecMap()); !reflect.DeepEqual(out, raw) {
+			t.Fatalf("spec did not round trip:\n%v\n%v", raw, out)
+		}
+	}
+
+	logSpec := map[string]interface{}{
+		"type": "log",
+		"name": "main",
+		"child": map[string]interface{}{
+			"type": "levelds",
+			"path": "datastore",
+		},
+	}
+	if spec, err := ParseDatastoreSpec(logSpec); err != nil {
+		t.Fatal(err)
+	} else if spec.(*LogSpec).Child.(*LeveldsSpec).Path != "datastore" {
+		t.Fatalf("unexpected spec: %+v", spec)
+	}
+}
+
+func TestParseDatastoreSpecErrors(t *testing.T) {
+	for _, c := range []struct {
+		spec map[string]interface{}
+		err  string
+	}{
+		{map[string]interface{}{"type": "flatfs", "path": "blocks", "shardfunc": "x"}, `missing "shardFunc"`},
+		{map[string]interface{}{"type": "levelds", "path": "datastore", "compresion": "none"}, "unknown keys: compresion"},
+		{map[string]interface{}{"type": "badgerds", "path": "badgerds", "truncate": "yes"}, `"truncate" must be a boolean`},
+		{map[string]interface{}{"type": "measure", "prefix": "p"}, `missing "child"`},
+		{map[string]interface{}{"type": "rocksdb"}, "unknown datastore type"},
+		{map[string]interface{}{"type": "mount", "mounts": []interface{}{
+			map[string]interface{}{"mountpoint": "/", "type": "levelds"},
+		}}, `mount /: levelds: missing "path"`},
+	} {
+		_, err := ParseDatastoreSpec(c.spec)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got %v", c.err, err)
+		}
+	}
+}
+
+func TestDatastoreSpecValidate(t *testing.T) {
+	leveldb := &LeveldsSpec{Path: "datastore"}
+	flatfs := func(shardFunc string) DatastoreSpec {
+		return &FlatfsSpec{Path: "blocks", ShardFunc: shardFunc}
+	}
+	for _, c := range []struct {
+		spec DatastoreSpec
+		err  string
+	}{
+		{&MountSpec{Mounts: []MountPoint{{"/blocks", flatfs("/repo/flatfs/shard/v1/next-to-last/2")}}}, `no datastore mounted at "/"`},
+		{&MountSpec{Mounts: []MountPoint{{"/", leveldb}, {"/blocks", leveldb}, {"/blocks/", leveldb}}}, "overlaps"},
+		{&MountSpec{Mounts: []MountPoint{{"/", leveldb}, {"blocks", leveldb}}}, "must start with '/'"},
+		{flatfs("/repo/flatfs/shard/v1/next-to-last"), "invalid shard function"},
+		{flatfs("/repo/flatfs/shard/v1/last/2"), "unknown function"},
+		{flatfs("/repo/flatfs/shard/v1/prefix/0"), "invalid length"},
+		{&LeveldsSpec{Path: "datastore", Compression: "zstd"}, "invalid compression"},
+	} {
+		err := c.spec.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got %v", c.err, err)
+		}
+	}
+}
+
+func TestDiskSpecBytes(t *testing.T) {
+	b, err := DiskSpecBytes(DefaultFlatfsSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"mounts":[{"mountpoint":"/blocks","path":"blocks","shardFunc":"/repo/flatfs/shard/v1/next-to-last/2","type":"flatfs"},{"mountpoint":"/","path":"datastore","type":"levelds"}],"type":"mount"}`
+	if string(b) != expected {
+		t.Fatalf("unexpected disk spec:\n%s", b)
+	}
+
+	b, err = DiskSpecBytes(DefaultBadgerSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"path":"badgerds","type":"badgerds"}` {
+		t.Fatalf("unexpected disk spec:\n%s", b)
+	}
+}
+
+func TestAdditionalDatastoreSpecs(t *testing.T) {
+	for _, spec := range []DatastoreSpec{
+		DefaultPebbleSpec(),
+		DefaultS3Spec("btfs-blocks", "", "http://127.0.0.1:9000"),
+	} {
+		if err := spec.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		raw := roundTripSpec(t, spec.SpecMap())
+		parsed, err := ParseDatastoreSpec(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out := roundTripSpec(t, parsed.SpecMap()); !reflect.DeepEqual(out, raw) {
+			t.Fatalf("spec did not round trip:\n%v\n%v", raw, out)
+		}
+	}
+
+	for _, c := range []struct {
+		spec DatastoreSpec
+		err  string
+	}{
+		{&PebbledsSpec{Path: "pebbleds", CacheSize: -1}, "cacheSize"},
+		{&S3dsSpec{Bucket: "Blocks", Region: "us-east-1"}, "invalid bucket name"},
+		{&S3dsSpec{Bucket: "blocks"}, "region or regionEndpoint"},
+		{&S3dsSpec{Bucket: "blocks", RegionEndpoint: "minio:9000"}, "invalid regionEndpoint"},
+		{&S3dsSpec{Bucket: "blocks", Region: "us-east-1", AccessKey: "key"}, "set together"},
+	} {
+		err := c.spec.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("expected error containing %q, got %v", c.err, err)
+		}
+	}
+
+	if _, err := ParseDatastoreSpec(map[string]interface{}{"type": "tiered"}); err == nil {
+		t.Fatal("expected tiered to be an unknown datastore type")
+	}
+}
+
+func TestS3CheckBucket(t *testing.T) {
+	// a MinIO-like server answering path-style HEAD bucket requests
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		switch r.URL.Path {
+		case "/btfs-blocks":
+			w.WriteHeader(http.StatusOK)
+		case "/private-blocks":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	for bucket, ok := range map[string]bool{
+		"btfs-blocks":    true,
+		"private-blocks": true,
+		"missing-blocks": false,
+	} {
+		spec := &S3dsSpec{Bucket: bucket, RegionEndpoint: ts.URL + "/"}
+		if err := spec.CheckBucket(ts.Client()); (err == nil) != ok {
+			t.Errorf("bucket %s: unexpected result %v", bucket, err)
+		}
+	}
+}
+
+func TestDatastoreProfiles(t *testing.T) {
+	c := &Config{Datastore: DefaultDatastoreConfig()}
+	if err := Profiles["s3ds"].Transform(c); err == nil {
+		t.Fatal("expected s3ds to require a bucket")
+	}
+	c.Datastore.S3 = &DatastoreS3{Bucket: "btfs-blocks", Region: "us-east-1"}
+	if err := Profiles["s3ds"].Transform(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Datastore.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Profiles["pebbleds"].Transform(c); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pebbleds", "s3ds"} {
+		if !Profiles[name].InitOnly {
+			t.Errorf("expected %s to be InitOnly", name)
+		}
+	}
+}
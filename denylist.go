@@ -0,0 +1,343 @@
+package config
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// GatewayDenylist configures content blocked on every gateway hostname.
+type GatewayDenylist struct {
+	// Files lists local denylist files in the compact denylist format. See
+	// ParseDenylist for the format.
+	Files []string `json:",omitempty"`
+
+	// RefreshInterval specifies how often the files are re-read. When
+	// unset, the files are only read on startup.
+	RefreshInterval Duration `json:",omitempty"`
+
+	// Entries lists additional denylist lines, applied after the files.
+	Entries []string `json:",omitempty"`
+}
+
+// Validate checks that the denylist files exist and that the inline entries
+// are well formed.
+func (d *GatewayDenylist) Validate() error {
+	if d.RefreshInterval < 0 {
+		return fmt.Errorf("RefreshInterval must not be negative")
+	}
+	for _, file := range d.Files {
+		if _, err := os.Stat(file); err != nil {
+			return err
+		}
+	}
+	_, lineErrs := ParseDenylist(denylistEntriesSource, strings.NewReader(strings.Join(d.Entries, "\n")))
+	if len(lineErrs) > 0 {
+		return lineErrs[0]
+	}
+	return nil
+}
+
+const denylistEntriesSource = "Gateway.Denylist.Entries"
+
+// DenylistLineError reports a malformed denylist line. Malformed lines are
+// skipped without discarding the rest of the list.
+type DenylistLineError struct {
+	Source string
+	Line   int
+	Err    error
+}
+
+func (e *DenylistLineError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Source, e.Line, e.Err)
+}
+
+// Denylist is a set of blocked content paths. The zero value is not usable,
+// use NewDenylist.
+type Denylist struct {
+	// blocked and allowed hold normalized keys, see denylistKey. allowed
+	// records negated entries so they also apply when merging lists.
+	blocked map[string]struct{}
+	allowed map[string]struct{}
+
+	// hashed counts the double-hashed keys in blocked, so lookups can
+	// skip hashing when there are none.
+	hashed int
+}
+
+// NewDenylist returns an empty denylist.
+func NewDenylist() *Denylist {
+	return &Denylist{
+		blocked: make(map[string]struct{}),
+		allowed: make(map[string]struct{}),
+	}
+}
+
+// LoadDenylist reads and merges the configured denylist files and inline
+// entries, in that order. Malformed lines are returned as line errors; err is
+// only set when a file cannot be read.
+func LoadDenylist(cfg *GatewayDenylist) (d *Denylist, lineErrs []error, err error) {
+	d = NewDenylist()
+	for _, file := range cfg.Files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+		list, errs := ParseDenylist(file, f)
+		f.Close()
+		d.Merge(list)
+		lineErrs = append(lineErrs, errs...)
+	}
+	list, errs := ParseDenylist(denylistEntriesSource, strings.NewReader(strings.Join(cfg.Entries, "\n")))
+	d.Merge(list)
+	return d, append(lineErrs, errs...), nil
+}
+
+// ParseDenylist parses a denylist in the compact denylist format:
+//
+//   - an optional header of `key: value` lines, terminated by a `---` line;
+//     a `---` line after an entry is malformed rather than a header end;
+//   - `/ipfs/<cid>` or a bare `<cid>` blocks the CID in all its encodings;
+//   - `/ipfs/<cid>/<path>` and `/ipns/<name>[/<path>]` block the path and
+//     everything below it;
+//   - `//<sha256 hex>` or `//<base58 sha2-256 multihash>` is a double-hashed
+//     entry, see DenylistHash;
+//   - a leading `!` negates an entry, unblocking content blocked by an
+//     earlier line or list;
+//   - blank lines and lines starting with `#` are ignored.
+//
+// Malformed lines are skipped and reported as *DenylistLineError. Read
+// errors are reported as a line error for the line that failed.
+func ParseDenylist(source string, r io.Reader) (*Denylist, []error) {
+	var (
+		lines    []string
+		lineErrs []error
+	)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		lineErrs = append(lineErrs, &DenylistLineError{Source: source, Line: len(lines) + 1, Err: err})
+	}
+
+	// skip the header if the list starts with one
+	first := 0
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "---" {
+			first = i + 1
+			break
+		}
+		if line != "" && !strings.HasPrefix(line, "#") && !isDenylistHeaderLine(line) {
+			break
+		}
+	}
+
+	d := NewDenylist()
+	for i := first; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		key, err := denylistKey(strings.TrimPrefix(line, "!"))
+		if err != nil {
+			lineErrs = append(lineErrs, &DenylistLineError{Source: source, Line: i + 1, Err: err})
+			continue
+		}
+		d.set(key, !negate)
+	}
+	return d, lineErrs
+}
+
+// isDenylistHeaderLine reports whether a line is a `key: value` header line
+// rather than an entry.
+func isDenylistHeaderLine(line string) bool {
+	return strings.Contains(line, ":") && !strings.HasPrefix(line, "/") && !strings.HasPrefix(line, "!")
+}
+
+func (d *Denylist) set(key string, blocked bool) {
+	_, present := d.blocked[key]
+	if strings.HasPrefix(key, "hash:") && present != blocked {
+		if blocked {
+			d.hashed++
+		} else {
+			d.hashed--
+		}
+	}
+	if blocked {
+		d.blocked[key] = struct{}{}
+		delete(d.allowed, key)
+	} else {
+		delete(d.blocked, key)
+		d.allowed[key] = struct{}{}
+	}
+}
+
+// Merge applies the entries of o on top of d. Negated entries in o unblock
+// content blocked in d.
+func (d *Denylist) Merge(o *Denylist) {
+	for key := range o.allowed {
+		d.set(key, false)
+	}
+	for key := range o.blocked {
+		d.set(key, true)
+	}
+}
+
+// Len returns the number of blocking entries.
+func (d *Denylist) Len() int {
+	return len(d.blocked)
+}
+
+// denylistKey normalizes a denylist entry into a lookup key. CIDs are keyed
+// by multihash so all encodings of the same content share a key.
+func denylistKey(entry string) (string, error) {
+	switch {
+	case strings.HasPrefix(entry, "//"):
+		digest, err := denylistDigest(entry[2:])
+		if err != nil {
+			return "", fmt.Errorf("invalid double-hashed entry %s: %s", entry, err)
+		}
+		return "hash:" + digest, nil
+	case path.Clean(entry) != strings.TrimSuffix(entry, "/"):
+		return "", fmt.Errorf("unclean content path: %s", entry)
+	case strings.HasPrefix(entry, "/ipfs/"):
+		c, rest := splitContentPath(entry[len("/ipfs/"):])
+		parsed, err := cid.Decode(c)
+		if err != nil {
+			return "", fmt.Errorf("invalid CID %q: %s", c, err)
+		}
+		return "ipfs:" + string(parsed.Hash()) + rest, nil
+	case strings.HasPrefix(entry, "/ipns/"):
+		name, rest := splitContentPath(entry[len("/ipns/"):])
+		if name == "" {
+			return "", fmt.Errorf("missing IPNS name: %s", entry)
+		}
+		return "ipns:" + name + rest, nil
+	case !strings.Contains(entry, "/"):
+		parsed, err := cid.Decode(entry)
+		if err != nil {
+			return "", fmt.Errorf("invalid CID %q: %s", entry, err)
+		}
+		return "ipfs:" + string(parsed.Hash()), nil
+	default:
+		return "", fmt.Errorf("unknown entry: %s", entry)
+	}
+}
+
+// denylistDigest returns the hex sha256 digest of a double-hashed entry given
+// either as hex or as a base58 sha2-256 multihash.
+func denylistDigest(s string) (string, error) {
+	if b, err := hex.DecodeString(s); err == nil {
+		if len(b) != sha256.Size {
+			return "", fmt.Errorf("expected a %d byte sha256 digest", sha256.Size)
+		}
+		return strings.ToLower(s), nil
+	}
+	h, err := mh.FromB58String(s)
+	if err != nil {
+		return "", err
+	}
+	decoded, err := mh.Decode(h)
+	if err != nil {
+		return "", err
+	}
+	if decoded.Code != mh.SHA2_256 {
+		return "", fmt.Errorf("unsupported hash function %s", decoded.Name)
+	}
+	return hex.EncodeToString(decoded.Digest), nil
+}
+
+// splitContentPath splits `<root>/a/b/` into the root and a cleaned `/a/b`.
+func splitContentPath(p string) (root, rest string) {
+	segments := strings.Split(p, "/")
+	root = segments[0]
+	for _, seg := range segments[1:] {
+		if seg != "" {
+			rest += "/" + seg
+		}
+	}
+	return root, rest
+}
+
+// DenylistHash returns the double-hashed entry, without the leading `//`, for
+// a CID and an optional path below it: the hex encoded sha256 of the base32
+// CIDv1 followed by `/` and the path.
+func DenylistHash(c cid.Cid, path string) string {
+	return denylistHash(cidV1String(c), path)
+}
+
+func denylistHash(v1, path string) string {
+	sum := sha256.Sum256([]byte(v1 + "/" + strings.Trim(path, "/")))
+	return hex.EncodeToString(sum[:])
+}
+
+// cidV1String returns the base32 CIDv1 form of c, the default for CIDv1.
+func cidV1String(c cid.Cid) string {
+	return cid.NewCidV1(c.Type(), c.Hash()).String()
+}
+
+// Blocked reports whether a content path such as `/ipfs/<cid>/a/b` or
+// `/ipns/<name>/a` is blocked, either directly or because one of its parent
+// paths is. The path is cleaned first, so "." and ".." segments are resolved
+// the way the gateway resolves them.
+func (d *Denylist) Blocked(contentPath string) bool {
+	if len(d.blocked) == 0 {
+		return false
+	}
+	contentPath = path.Clean(contentPath)
+	var (
+		prefix string
+		v1     string
+	)
+	switch {
+	case strings.HasPrefix(contentPath, "/ipfs/"):
+		root, _ := splitContentPath(contentPath[len("/ipfs/"):])
+		parsed, err := cid.Decode(root)
+		if err != nil {
+			return false
+		}
+		if d.hashed > 0 {
+			v1 = cidV1String(parsed)
+		}
+		prefix = "ipfs:" + string(parsed.Hash())
+		contentPath = contentPath[len("/ipfs/"):]
+	case strings.HasPrefix(contentPath, "/ipns/"):
+		root, _ := splitContentPath(contentPath[len("/ipns/"):])
+		prefix = "ipns:" + root
+		contentPath = contentPath[len("/ipns/"):]
+	default:
+		return false
+	}
+
+	_, rest := splitContentPath(contentPath)
+	key := prefix
+	sub := ""
+	segments := strings.Split(rest, "/")[1:]
+	for i := 0; ; i++ {
+		if _, ok := d.blocked[key]; ok {
+			return true
+		}
+		if v1 != "" {
+			if _, ok := d.blocked["hash:"+denylistHash(v1, sub)]; ok {
+				return true
+			}
+		}
+		if i == len(segments) {
+			return false
+		}
+		key += "/" + segments[i]
+		sub = strings.TrimPrefix(sub+"/"+segments[i], "/")
+	}
+}
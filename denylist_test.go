@@ -0,0 +1,157 @@
+package config
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+const (
+	testCIDv0  = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+	testCIDv0B = "QmcZf59bWwK5XFi76CZX8cbJ4BhTzzA3gU1ZjYZcYW3dwt"
+)
+
+func TestParseDenylist(t *testing.T) {
+	c, err := cid.Decode(testCIDv0B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := cid.NewCidV1(cid.DagProtobuf, c.Hash()).String()
+
+	list := strings.Join([]string{
+		"version: 1",
+		"name: test list",
+		"---",
+		"# a comment",
+		"/ipfs/" + testCIDv0,
+		"/ipfs/" + v1 + "/private/",
+		"/ipns/bad.example.org",
+		"//" + DenylistHash(c, "leaked.txt"),
+		"/ipfs/not-a-cid",
+		"",
+		"//abcdef",
+	}, "\n")
+
+	d, lineErrs := ParseDenylist("test", strings.NewReader(list))
+	if len(lineErrs) != 2 {
+		t.Fatalf("expected 2 malformed lines, got %v", lineErrs)
+	}
+	if lerr, ok := lineErrs[0].(*DenylistLineError); !ok || lerr.Line != 9 {
+		t.Fatalf("unexpected line error: %v", lineErrs[0])
+	}
+	if d.Len() != 4 {
+		t.Fatalf("expected 4 entries, got %d", d.Len())
+	}
+
+	for path, blocked := range map[string]bool{
+		"/ipfs/" + testCIDv0:                    true,
+		"/ipfs/" + testCIDv0 + "/a/b":           true,
+		"/ipfs/" + testCIDv0B:                   false,
+		"/ipfs/" + testCIDv0B + "/private":      true,
+		"/ipfs/" + testCIDv0B + "/private/x/y":  true,
+		"/ipfs/" + testCIDv0B + "/privatex":     false,
+		"/ipfs/" + testCIDv0B + "/leaked.txt":   true,
+		"/ipfs/" + v1 + "/leaked.txt/":          true,
+		"/ipns/bad.example.org/index.html":      true,
+		"/ipns/good.example.org":                false,
+		"/ipfs/not-a-cid":                       false,
+		"/ipfs/" + testCIDv0B + "/x/../private": true,
+		"/ipfs/" + testCIDv0B + "/./private":    true,
+		"/ipfs/" + testCIDv0B + "//private":     true,
+		"/ipfs/" + testCIDv0B + "/./leaked.txt": true,
+	} {
+		if d.Blocked(path) != blocked {
+			t.Fatalf("%s: expected blocked=%v", path, blocked)
+		}
+	}
+}
+
+func TestParseDenylistWithoutHeader(t *testing.T) {
+	list := strings.Join([]string{
+		"# no header",
+		"/ipfs/" + testCIDv0,
+		"---",
+		"/ipfs/" + testCIDv0B,
+		"/ipfs/" + testCIDv0 + "/a/../b",
+	}, "\n")
+	d, lineErrs := ParseDenylist("test", strings.NewReader(list))
+	if len(lineErrs) != 2 {
+		t.Fatalf("expected the stray --- and the unclean path to be malformed, got %v", lineErrs)
+	}
+	if !d.Blocked("/ipfs/"+testCIDv0) || !d.Blocked("/ipfs/"+testCIDv0B) {
+		t.Fatal("expected the entries around a stray --- to be kept")
+	}
+}
+
+func TestParseDenylistMultihash(t *testing.T) {
+	c, err := cid.Decode(testCIDv0B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest, err := hex.DecodeString(DenylistHash(c, "leaked.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := mh.Encode(digest, mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, lineErrs := ParseDenylist("test", strings.NewReader("//"+mh.Multihash(h).B58String()))
+	if len(lineErrs) != 0 {
+		t.Fatal(lineErrs)
+	}
+	if !d.Blocked("/ipfs/" + testCIDv0B + "/leaked.txt") {
+		t.Fatal("expected the multihash form of a double-hashed entry to block")
+	}
+
+	h, err = mh.Sum([]byte("x"), mh.SHA1, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, lineErrs := ParseDenylist("test", strings.NewReader("//"+mh.Multihash(h).B58String())); len(lineErrs) != 1 {
+		t.Fatal("expected a non sha2-256 multihash to be rejected")
+	}
+}
+
+func TestMergeDenylist(t *testing.T) {
+	a, errs := ParseDenylist("a", strings.NewReader("/ipfs/"+testCIDv0+"\n/ipfs/"+testCIDv0B))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	b, errs := ParseDenylist("b", strings.NewReader("!/ipfs/"+testCIDv0B+"\n/ipns/bad.example.org"))
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+
+	d := NewDenylist()
+	d.Merge(a)
+	d.Merge(b)
+	if !d.Blocked("/ipfs/"+testCIDv0) || !d.Blocked("/ipns/bad.example.org") {
+		t.Fatal("expected entries of both lists to be blocked")
+	}
+	if d.Blocked("/ipfs/" + testCIDv0B) {
+		t.Fatal("expected negated entry of a later list to unblock")
+	}
+}
+
+func BenchmarkDenylistBlocked(b *testing.B) {
+	c, err := cid.Decode(testCIDv0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var lines []string
+	for i := 0; i < 100000; i++ {
+		lines = append(lines, "//"+DenylistHash(c, fmt.Sprintf("file-%d", i)))
+	}
+	d, _ := ParseDenylist("bench", strings.NewReader(strings.Join(lines, "\n")))
+	path := "/ipfs/" + testCIDv0B + "/some/nested/file.txt"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Blocked(path)
+	}
+}
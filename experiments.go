@@ -1,5 +1,7 @@
 package config
 
+// Experiments holds the feature flags. See Features for what each flag
+// enables and how the flags depend on each other.
 type Experiments struct {
 	FilestoreEnabled     bool
 	UrlstoreEnabled      bool
@@ -13,7 +15,7 @@ type Experiments struct {
 	Analytics            bool
 	RemoveOnUnpin        bool
 	HostsSyncEnabled     bool
-	HostsSyncMode        string
+	HostsSyncMode        HostsSyncMode
 	DisableAutoUpdate    bool
 	HostRepairEnabled    bool
 	HostChallengeEnabled bool
@@ -0,0 +1,235 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FeatureStage describes the maturity of a feature.
+type FeatureStage string
+
+const (
+	FeatureExperimental FeatureStage = "experimental"
+	FeatureBeta         FeatureStage = "beta"
+	FeatureStable       FeatureStage = "stable"
+)
+
+// Feature describes a feature toggled by one of the Experiments flags.
+type Feature struct {
+	Name        string
+	Description string
+	Stage       FeatureStage
+
+	// Requires lists the features that must be enabled with this one.
+	Requires []string
+
+	// flag returns the Experiments field backing the feature.
+	flag func(e *Experiments) *bool
+}
+
+// features is the registry of features, backed by the Experiments fields.
+var features = []Feature{
+	{
+		Name:        "filestore",
+		Description: "Add files without copying them into the datastore.",
+		Stage:       FeatureExperimental,
+		flag:        func(e *Experiments) *bool { return &e.FilestoreEnabled },
+	},
+	{
+		Name:        "urlstore",
+		Description: "Add content from URLs without copying it into the datastore.",
+		Stage:       FeatureExperimental,
+		flag:        func(e *Experiments) *bool { return &e.UrlstoreEnabled },
+	},
+	{
+		Name:        "sharding",
+		Description: "Shard large directories.",
+		Stage:       FeatureExperimental,
+		flag:        func(e *Experiments) *bool { return &e.ShardingEnabled },
+	},
+	{
+		Name:        "graphsync",
+		Description: "Serve content over the graphsync protocol.",
+		Stage:       FeatureExperimental,
+		flag:        func(e *Experiments) *bool { return &e.GraphsyncEnabled },
+	},
+	{
+		Name:        "libp2p-stream-mounting",
+		Description: "Forward libp2p streams to local services, used by the remote API.",
+		Stage:       FeatureStable,
+		flag:        func(e *Experiments) *bool { return &e.Libp2pStreamMounting },
+	},
+	{
+		Name:        "p2p-http-proxy",
+		Description: "Proxy HTTP requests to remote peers through the gateway.",
+		Stage:       FeatureExperimental,
+		Requires:    []string{"libp2p-stream-mounting"},
+		flag:        func(e *Experiments) *bool { return &e.P2pHttpProxy },
+	},
+	{
+		Name:        "strategic-providing",
+		Description: "Replace the reprovider with strategic providing.",
+		Stage:       FeatureExperimental,
+		flag:        func(e *Experiments) *bool { return &e.StrategicProviding },
+	},
+	{
+		Name:        "storage-host",
+		Description: "Sell storage space to renters.",
+		Stage:       FeatureStable,
+		Requires:    []string{"libp2p-stream-mounting"},
+		flag:        func(e *Experiments) *bool { return &e.StorageHostEnabled },
+	},
+	{
+		Name:        "storage-client",
+		Description: "Pay hosts to store files.",
+		Stage:       FeatureStable,
+		Requires:    []string{"libp2p-stream-mounting", "hosts-sync"},
+		flag:        func(e *Experiments) *bool { return &e.StorageClientEnabled },
+	},
+	{
+		Name:        "analytics",
+		Description: "Report node statistics to the status server.",
+		Stage:       FeatureStable,
+		flag:        func(e *Experiments) *bool { return &e.Analytics },
+	},
+	{
+		Name:        "remove-on-unpin",
+		Description: "Remove blocks from the datastore when they are unpinned.",
+		Stage:       FeatureBeta,
+		flag:        func(e *Experiments) *bool { return &e.RemoveOnUnpin },
+	},
+	{
+		Name:        "hosts-sync",
+		Description: "Periodically sync the list of storage hosts from the hub.",
+		Stage:       FeatureStable,
+		flag:        func(e *Experiments) *bool { return &e.HostsSyncEnabled },
+	},
+	{
+		Name:        "disable-auto-update",
+		Description: "Do not update the node automatically.",
+		Stage:       FeatureStable,
+		flag:        func(e *Experiments) *bool { return &e.DisableAutoUpdate },
+	},
+	{
+		Name:        "host-repair",
+		Description: "Repair lost shards on behalf of the network.",
+		Stage:       FeatureBeta,
+		Requires:    []string{"storage-host", "hosts-sync"},
+		flag:        func(e *Experiments) *bool { return &e.HostRepairEnabled },
+	},
+	{
+		Name:        "host-challenge",
+		Description: "Challenge hosts to prove they store their shards.",
+		Stage:       FeatureBeta,
+		Requires:    []string{"libp2p-stream-mounting"},
+		flag:        func(e *Experiments) *bool { return &e.HostChallengeEnabled },
+	},
+}
+
+// Features returns a copy of the feature registry.
+func Features() []Feature {
+	return append([]Feature(nil), features...)
+}
+
+// LookupFeature returns the registered feature with the given name.
+func LookupFeature(name string) (Feature, bool) {
+	for _, f := range features {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Feature{}, false
+}
+
+// Enabled reports whether a feature is enabled. Unknown features are not.
+func (e *Experiments) Enabled(name string) bool {
+	f, ok := LookupFeature(name)
+	return ok && *f.flag(e)
+}
+
+// EnabledFeatures lists the enabled features in registry order.
+func (e *Experiments) EnabledFeatures() []string {
+	var names []string
+	for _, f := range features {
+		if *f.flag(e) {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// Enable enables features along with the features they require. Nothing is
+// changed if a name is unknown.
+func (e *Experiments) Enable(names ...string) error {
+	var order []Feature
+	seen := make(map[string]bool)
+	var visit func(name string) error
+	visit = func(name string) error {
+		if seen[name] {
+			return nil
+		}
+		seen[name] = true
+		f, ok := LookupFeature(name)
+		if !ok {
+			return fmt.Errorf("unknown feature: %s", name)
+		}
+		for _, dep := range f.Requires {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		order = append(order, f)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	for _, f := range order {
+		*f.flag(e) = true
+	}
+	return nil
+}
+
+// Disable disables a feature. It fails when an enabled feature requires it.
+func (e *Experiments) Disable(name string) error {
+	f, ok := LookupFeature(name)
+	if !ok {
+		return fmt.Errorf("unknown feature: %s", name)
+	}
+	var dependents []string
+	for _, other := range features {
+		if !*other.flag(e) {
+			continue
+		}
+		for _, dep := range other.Requires {
+			if dep == name {
+				dependents = append(dependents, other.Name)
+			}
+		}
+	}
+	if len(dependents) > 0 {
+		return fmt.Errorf("feature %s is required by %s", name, strings.Join(dependents, ", "))
+	}
+	*f.flag(e) = false
+	return nil
+}
+
+// Validate reports enabled features whose requirements are not enabled.
+func (e *Experiments) Validate() error {
+	if err := validateHostsSyncMode(e.HostsSyncMode); err != nil {
+		return fmt.Errorf("HostsSyncMode: %s", err)
+	}
+	for _, f := range features {
+		if !*f.flag(e) {
+			continue
+		}
+		for _, dep := range f.Requires {
+			if !e.Enabled(dep) {
+				return fmt.Errorf("feature %s requires %s", f.Name, dep)
+			}
+		}
+	}
+	return nil
+}
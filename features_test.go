@@ -0,0 +1,75 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFeatureRegistry(t *testing.T) {
+	names := make(map[string]bool)
+	for _, f := range Features() {
+		if names[f.Name] {
+			t.Fatalf("duplicate feature %s", f.Name)
+		}
+		names[f.Name] = true
+		if f.flag == nil || f.Description == "" {
+			t.Fatalf("incomplete feature %s", f.Name)
+		}
+	}
+	for _, f := range Features() {
+		for _, dep := range f.Requires {
+			if !names[dep] {
+				t.Fatalf("feature %s refers to unknown feature %s", f.Name, dep)
+			}
+		}
+	}
+}
+
+func TestFeatureEnable(t *testing.T) {
+	var e Experiments
+	if err := e.Enable("host-repair"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"libp2p-stream-mounting", "storage-host", "hosts-sync", "host-repair"}
+	if !reflect.DeepEqual(e.EnabledFeatures(), expected) {
+		t.Fatalf("expected %v, got %v", expected, e.EnabledFeatures())
+	}
+	if !e.StorageHostEnabled || !e.HostsSyncEnabled {
+		t.Fatal("expected the backing fields to be set")
+	}
+	if err := e.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.Disable("hosts-sync"); err == nil {
+		t.Fatal("expected disabling a required feature to fail")
+	}
+	if err := e.Enable("no-such-feature"); err == nil {
+		t.Fatal("expected unknown features to be rejected")
+	}
+}
+
+func TestFeatureValidate(t *testing.T) {
+	e := Experiments{StorageHostEnabled: true}
+	if err := e.Validate(); err == nil {
+		t.Fatal("expected a missing requirement to be reported")
+	}
+
+	registry := Features()
+	registry[0].Requires = []string{"sharding"}
+	if f, _ := LookupFeature(registry[0].Name); len(f.Requires) != 0 {
+		t.Fatal("expected Features to return a copy of the registry")
+	}
+}
+
+func TestFeatureProfiles(t *testing.T) {
+	for _, name := range []string{"storage-host", "storage-repairer-dev", "storage-challenger", "storage-client-testnet"} {
+		c := new(Config)
+		if err := Profiles[name].Transform(c); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Experimental.Validate(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+}
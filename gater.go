@@ -0,0 +1,309 @@
+package config
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/libp2p/go-libp2p-core/connmgr"
+	"github.com/libp2p/go-libp2p-core/control"
+	"github.com/libp2p/go-libp2p-core/network"
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// GaterAction is the decision taken by a connection gating rule.
+type GaterAction string
+
+const (
+	// GaterAllow lets the connection through.
+	GaterAllow GaterAction = "allow"
+	// GaterDeny blocks the connection.
+	GaterDeny GaterAction = "deny"
+)
+
+// PrivateAddrsRuleSet is the name of the rule set denying the private,
+// local only and unrouteable prefixes used by the server profile.
+const PrivateAddrsRuleSet = "private-addrs"
+
+// gaterRuleSets holds named, reusable gating rules. A GaterRule refers to one
+// of them through its RuleSet field.
+var gaterRuleSets = map[string]GaterRule{
+	// The prefixes are private, local only, or unrouteable according to
+	// https://www.iana.org/assignments/iana-ipv4-special-registry/iana-ipv4-special-registry.xhtml
+	// and https://www.iana.org/assignments/iana-ipv6-special-registry/iana-ipv6-special-registry.xhtml
+	PrivateAddrsRuleSet: {
+		Action: GaterDeny,
+		CIDRs: []string{
+			"/ip4/10.0.0.0/ipcidr/8",
+			"/ip4/100.64.0.0/ipcidr/10",
+			"/ip4/169.254.0.0/ipcidr/16",
+			"/ip4/172.16.0.0/ipcidr/12",
+			"/ip4/192.0.0.0/ipcidr/24",
+			"/ip4/192.0.0.0/ipcidr/29",
+			"/ip4/192.0.0.8/ipcidr/32",
+			"/ip4/192.0.0.170/ipcidr/32",
+			"/ip4/192.0.0.171/ipcidr/32",
+			"/ip4/192.0.2.0/ipcidr/24",
+			"/ip4/192.168.0.0/ipcidr/16",
+			"/ip4/198.18.0.0/ipcidr/15",
+			"/ip4/198.51.100.0/ipcidr/24",
+			"/ip4/203.0.113.0/ipcidr/24",
+			"/ip4/240.0.0.0/ipcidr/4",
+			"/ip6/100::/ipcidr/64",
+			"/ip6/2001:2::/ipcidr/48",
+			"/ip6/2001:db8::/ipcidr/32",
+			"/ip6/fc00::/ipcidr/7",
+			"/ip6/fe80::/ipcidr/10",
+		},
+	},
+}
+
+// GaterRuleSet returns a copy of the named rule set.
+func GaterRuleSet(name string) (GaterRule, bool) {
+	set, ok := gaterRuleSets[name]
+	if !ok {
+		return GaterRule{}, false
+	}
+	set.CIDRs = append([]string(nil), set.CIDRs...)
+	set.Peers = append([]string(nil), set.Peers...)
+	set.Protocols = append([]string(nil), set.Protocols...)
+	return set, true
+}
+
+// GaterConfig configures which peers and addresses the node may connect to.
+type GaterConfig struct {
+	// DefaultAction is taken when no rule matches. Defaults to "allow".
+	DefaultAction GaterAction `json:",omitempty"`
+
+	// Rules are evaluated in order and the first matching rule decides.
+	// Swarm.AddrFilters are always denied ahead of these rules.
+	Rules []GaterRule `json:",omitempty"`
+}
+
+// GaterRule matches connections by remote address, peer ID and protocol. A
+// rule matches when every non-empty criteria list has a match; a rule without
+// criteria matches every connection.
+type GaterRule struct {
+	// Action is either "allow" or "deny". It may be omitted when RuleSet
+	// is set, in which case the action of the rule set is used.
+	Action GaterAction `json:",omitempty"`
+
+	// RuleSet names a built-in rule set, see GaterRuleSet, whose criteria
+	// this rule uses. It cannot be combined with criteria on the rule
+	// itself.
+	RuleSet string `json:",omitempty"`
+
+	// CIDRs lists multiaddr CIDRs such as `/ip4/10.0.0.0/ipcidr/8`.
+	CIDRs []string `json:",omitempty"`
+
+	// Peers lists peer IDs.
+	Peers []string `json:",omitempty"`
+
+	// Protocols lists multiaddr protocol names such as "quic" or "ws".
+	Protocols []string `json:",omitempty"`
+}
+
+// Validate checks that all rules can be compiled.
+func (g *GaterConfig) Validate() error {
+	_, err := newGater(nil, g)
+	return err
+}
+
+// Gater is a libp2p connection gater evaluating the gating policy of a
+// Config.
+type Gater struct {
+	rules        []gaterRule
+	defaultAllow bool
+}
+
+var _ connmgr.ConnectionGater = (*Gater)(nil)
+
+type gaterRule struct {
+	allow     bool
+	nets      []*net.IPNet
+	peers     map[peer.ID]struct{}
+	protocols map[string]struct{}
+}
+
+// NewGater builds a Gater from Swarm.AddrFilters and Swarm.ConnectionGater.
+func NewGater(c *Config) (*Gater, error) {
+	return newGater(c.Swarm.AddrFilters, &c.Swarm.ConnectionGater)
+}
+
+func newGater(addrFilters []string, cfg *GaterConfig) (*Gater, error) {
+	g := new(Gater)
+	switch cfg.DefaultAction {
+	case "", GaterAllow:
+		g.defaultAllow = true
+	case GaterDeny:
+	default:
+		return nil, fmt.Errorf("invalid default gater action: %s", cfg.DefaultAction)
+	}
+
+	if len(addrFilters) > 0 {
+		r, err := compileGaterRule(GaterRule{Action: GaterDeny, CIDRs: addrFilters})
+		if err != nil {
+			return nil, fmt.Errorf("AddrFilters: %s", err)
+		}
+		g.rules = append(g.rules, r)
+	}
+	for i, rule := range cfg.Rules {
+		if rule.RuleSet != "" {
+			if len(rule.CIDRs) > 0 || len(rule.Peers) > 0 || len(rule.Protocols) > 0 {
+				return nil, fmt.Errorf("rule %d: RuleSet cannot be combined with CIDRs, Peers or Protocols", i)
+			}
+			set, ok := GaterRuleSet(rule.RuleSet)
+			if !ok {
+				return nil, fmt.Errorf("rule %d: unknown rule set: %s", i, rule.RuleSet)
+			}
+			if rule.Action != "" {
+				set.Action = rule.Action
+			}
+			rule = set
+		}
+		r, err := compileGaterRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %s", i, err)
+		}
+		g.rules = append(g.rules, r)
+	}
+	return g, nil
+}
+
+func compileGaterRule(rule GaterRule) (gaterRule, error) {
+	var r gaterRule
+	switch rule.Action {
+	case GaterAllow:
+		r.allow = true
+	case GaterDeny:
+	default:
+		return r, fmt.Errorf("invalid gater action: %q", rule.Action)
+	}
+	for _, s := range rule.CIDRs {
+		ipnet, err := parseIPCIDR(s)
+		if err != nil {
+			return r, err
+		}
+		r.nets = append(r.nets, ipnet)
+	}
+	if len(rule.Peers) > 0 {
+		r.peers = make(map[peer.ID]struct{}, len(rule.Peers))
+		for _, s := range rule.Peers {
+			id, err := peer.Decode(s)
+			if err != nil {
+				return r, fmt.Errorf("invalid peer ID %q: %s", s, err)
+			}
+			r.peers[id] = struct{}{}
+		}
+	}
+	if len(rule.Protocols) > 0 {
+		r.protocols = make(map[string]struct{}, len(rule.Protocols))
+		for _, s := range rule.Protocols {
+			if err := validateProtocolName(s); err != nil {
+				return r, err
+			}
+			r.protocols[s] = struct{}{}
+		}
+	}
+	return r, nil
+}
+
+// match reports whether the rule matches. When the rule depends on a peer ID
+// or address that is not known yet, decided is false.
+func (r *gaterRule) match(p peer.ID, addr ma.Multiaddr) (matched, decided bool) {
+	if r.peers != nil {
+		if p == "" {
+			return false, false
+		}
+		if _, ok := r.peers[p]; !ok {
+			return false, true
+		}
+	}
+	if r.nets == nil && r.protocols == nil {
+		return true, true
+	}
+	if addr == nil {
+		return false, false
+	}
+	if r.nets != nil {
+		ip := addrIP(addr)
+		if ip == nil {
+			return false, true
+		}
+		found := false
+		for _, ipnet := range r.nets {
+			if ipnet.Contains(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, true
+		}
+	}
+	if r.protocols != nil {
+		found := false
+		for _, proto := range addr.Protocols() {
+			if _, ok := r.protocols[proto.Name]; ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+func addrIP(addr ma.Multiaddr) net.IP {
+	if v, err := addr.ValueForProtocol(ma.P_IP4); err == nil {
+		return net.ParseIP(v)
+	}
+	if v, err := addr.ValueForProtocol(ma.P_IP6); err == nil {
+		return net.ParseIP(v)
+	}
+	return nil
+}
+
+// Allow evaluates the rules for a peer ID and remote address. Either may be
+// empty when not known yet. If the first rule that could match depends on
+// the missing information, the connection is allowed so that a later check
+// with more information can decide.
+func (g *Gater) Allow(p peer.ID, addr ma.Multiaddr) bool {
+	for i := range g.rules {
+		matched, decided := g.rules[i].match(p, addr)
+		if !decided {
+			return true
+		}
+		if matched {
+			return g.rules[i].allow
+		}
+	}
+	return g.defaultAllow
+}
+
+// InterceptPeerDial implements connmgr.ConnectionGater.
+func (g *Gater) InterceptPeerDial(p peer.ID) bool {
+	return g.Allow(p, nil)
+}
+
+// InterceptAddrDial implements connmgr.ConnectionGater.
+func (g *Gater) InterceptAddrDial(p peer.ID, addr ma.Multiaddr) bool {
+	return g.Allow(p, addr)
+}
+
+// InterceptAccept implements connmgr.ConnectionGater.
+func (g *Gater) InterceptAccept(cm network.ConnMultiaddrs) bool {
+	return g.Allow("", cm.RemoteMultiaddr())
+}
+
+// InterceptSecured implements connmgr.ConnectionGater.
+func (g *Gater) InterceptSecured(_ network.Direction, p peer.ID, cm network.ConnMultiaddrs) bool {
+	return g.Allow(p, cm.RemoteMultiaddr())
+}
+
+// InterceptUpgraded implements connmgr.ConnectionGater.
+func (g *Gater) InterceptUpgraded(network.Conn) (bool, control.DisconnectReason) {
+	return true, 0
+}
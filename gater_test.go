@@ -0,0 +1,133 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+const (
+	testPeerA = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+	testPeerB = "QmcZf59bWwK5XFi76CZX8cbJ4BhTzzA3gU1ZjYZcYW3dwt"
+)
+
+func mustGater(t *testing.T, c *Config) *Gater {
+	t.Helper()
+	g, err := NewGater(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func mustPeer(t *testing.T, s string) peer.ID {
+	t.Helper()
+	id, err := peer.Decode(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestGaterRuleOrder(t *testing.T) {
+	c := new(Config)
+	c.Swarm.ConnectionGater = GaterConfig{
+		DefaultAction: GaterDeny,
+		Rules: []GaterRule{
+			{Action: GaterDeny, Peers: []string{testPeerB}},
+			{Action: GaterAllow, CIDRs: []string{"/ip4/192.168.0.0/ipcidr/16"}},
+			{Action: GaterAllow, Protocols: []string{"quic"}},
+		},
+	}
+	g := mustGater(t, c)
+	a, b := mustPeer(t, testPeerA), mustPeer(t, testPeerB)
+	lan := ma.StringCast("/ip4/192.168.1.5/tcp/4001")
+	wan := ma.StringCast("/ip4/8.8.8.8/tcp/4001")
+	wanQUIC := ma.StringCast("/ip4/8.8.8.8/udp/4001/quic")
+
+	if !g.Allow(a, lan) {
+		t.Fatal("expected LAN address to be allowed")
+	}
+	if g.Allow(b, lan) {
+		t.Fatal("expected the earlier peer rule to win over the CIDR rule")
+	}
+	if g.Allow(a, wan) {
+		t.Fatal("expected the default action to deny")
+	}
+	if !g.Allow(a, wanQUIC) {
+		t.Fatal("expected QUIC to be allowed by protocol")
+	}
+
+	// without an address, the CIDR rule cannot decide yet
+	if !g.InterceptPeerDial(a) {
+		t.Fatal("expected peer dial to be deferred to the address check")
+	}
+	if g.InterceptPeerDial(b) {
+		t.Fatal("expected denied peer to be rejected before dialing")
+	}
+}
+
+func TestGaterAddrFiltersAndRuleSets(t *testing.T) {
+	c := new(Config)
+	c.Swarm.AddrFilters = []string{"/ip4/1.2.3.0/ipcidr/24"}
+	c.Swarm.ConnectionGater.Rules = []GaterRule{
+		{Action: GaterAllow, Peers: []string{testPeerA}},
+		{RuleSet: PrivateAddrsRuleSet},
+	}
+	g := mustGater(t, c)
+	a, b := mustPeer(t, testPeerA), mustPeer(t, testPeerB)
+
+	if g.Allow(a, ma.StringCast("/ip4/1.2.3.4/tcp/4001")) {
+		t.Fatal("expected AddrFilters to take precedence over allow rules")
+	}
+	if !g.Allow(a, ma.StringCast("/ip4/10.0.0.1/tcp/4001")) {
+		t.Fatal("expected allowed peer to bypass the private address rule set")
+	}
+	if g.Allow(b, ma.StringCast("/ip4/10.0.0.1/tcp/4001")) {
+		t.Fatal("expected private address to be denied by the rule set")
+	}
+	if !g.Allow(b, ma.StringCast("/ip4/8.8.8.8/tcp/4001")) {
+		t.Fatal("expected public address to be allowed by default")
+	}
+}
+
+func TestGaterConfigValidate(t *testing.T) {
+	for _, g := range []GaterConfig{
+		{DefaultAction: "maybe"},
+		{Rules: []GaterRule{{Action: "maybe"}}},
+		{Rules: []GaterRule{{RuleSet: "unknown"}}},
+		{Rules: []GaterRule{{RuleSet: PrivateAddrsRuleSet, Peers: []string{testPeerA}}}},
+		{Rules: []GaterRule{{Action: GaterDeny, CIDRs: []string{"10.0.0.0/8"}}}},
+		{Rules: []GaterRule{{Action: GaterDeny, Peers: []string{"notapeer"}}}},
+		{Rules: []GaterRule{{Action: GaterDeny, Protocols: []string{"qiuc"}}}},
+		{Rules: []GaterRule{{Action: GaterDeny, Protocols: []string{""}}}},
+	} {
+		if err := g.Validate(); err == nil {
+			t.Fatalf("expected %+v to be rejected", g)
+		}
+	}
+}
+
+func TestGaterRuleSetCopy(t *testing.T) {
+	set, ok := GaterRuleSet(PrivateAddrsRuleSet)
+	if !ok {
+		t.Fatal("expected the private address rule set")
+	}
+	set.CIDRs[0] = "/ip4/8.8.8.0/ipcidr/24"
+	if again, _ := GaterRuleSet(PrivateAddrsRuleSet); again.CIDRs[0] == set.CIDRs[0] {
+		t.Fatal("expected the built-in rule set to be unaffected by callers")
+	}
+	if _, ok := GaterRuleSet("unknown"); ok {
+		t.Fatal("expected unknown rule sets to be missing")
+	}
+
+	c := new(Config)
+	if err := Profiles["server"].Transform(c); err != nil {
+		t.Fatal(err)
+	}
+	c.Swarm.AddrFilters[0] = "/ip4/8.8.8.0/ipcidr/24"
+	if again, _ := GaterRuleSet(PrivateAddrsRuleSet); again.CIDRs[0] == c.Swarm.AddrFilters[0] {
+		t.Fatal("expected the built-in rule set to be unaffected by the server profile")
+	}
+}
@@ -1,5 +1,14 @@
 package config
 
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+)
+
 type GatewaySpec struct {
 	// Paths is explicit list of path prefixes that should be handled by
 	// this gateway. Example: `["/ipfs", "/ipns", "/api"]`
@@ -18,6 +27,44 @@ type GatewaySpec struct {
 	// NoDNSLink configures this gateway to _not_ resolve DNSLink for the FQDN
 	// provided in `Host` HTTP header.
 	NoDNSLink bool
+
+	// InlineDNSLink configures this subdomain gateway to inline DNSLink
+	// names into a single DNS label (en.example.org becomes
+	// en-example-org), so they are covered by a wildcard TLS certificate.
+	InlineDNSLink Flag `json:",omitempty"`
+
+	// NoFetch overrides Gateway.NoFetch for this hostname.
+	NoFetch Flag `json:",omitempty"`
+
+	// HTTPHeaders overrides entries of Gateway.HTTPHeaders for this
+	// hostname, e.g. to serve stricter CORS headers. Headers not listed
+	// here are inherited.
+	HTTPHeaders map[string][]string `json:",omitempty"`
+
+	// RateLimit limits the requests served for this hostname.
+	RateLimit *GatewayRateLimit `json:",omitempty"`
+
+	// MaxResponseSize is the largest response body in bytes served for
+	// this hostname. Zero means unlimited.
+	MaxResponseSize int64 `json:",omitempty"`
+
+	// Allowlist, when not empty, restricts this hostname to the listed
+	// content. Entries are either CIDs or content paths such as
+	// `/ipfs/<cid>/docs` or `/ipns/example.org`.
+	Allowlist []string `json:",omitempty"`
+
+	// Denylist blocks the listed content on this hostname. It uses the
+	// same format as Allowlist and takes precedence over it.
+	Denylist []string `json:",omitempty"`
+}
+
+// GatewayRateLimit limits the request rate of a gateway hostname.
+type GatewayRateLimit struct {
+	// RequestsPerMinute is the sustained number of requests allowed per
+	// client IP.
+	RequestsPerMinute int
+	// Burst is the number of requests allowed above the sustained rate.
+	Burst int `json:",omitempty"`
 }
 
 // Gateway contains options for the HTTP gateway server.
@@ -27,6 +74,9 @@ type Gateway struct {
 	// gateway.
 	HTTPHeaders map[string][]string // HTTP headers to return with the gateway
 
+	// CORS configures cross-origin requests to the gateway.
+	CORS *CORS `json:",omitempty"`
+
 	// RootRedirect is the path to which requests to `/` on this gateway
 	// should be redirected.
 	RootRedirect string
@@ -53,8 +103,9 @@ type Gateway struct {
 	//  }
 	PathPrefixes []string
 
-	// FIXME: Not yet implemented
-	APICommands []string
+	// APICommands lists the API commands this gateway exposes under
+	// /api/v1/, see APICommandRule. Nothing is exposed when empty.
+	APICommands []APICommandRule
 
 	// NoFetch configures the gateway to _not_ fetch blocks in response to
 	// requests.
@@ -65,7 +116,279 @@ type Gateway struct {
 	// This flag can be overriden per FQDN in PublicGateways.
 	NoDNSLink bool
 
+	// TLS configures the HTTPS gateway.
+	TLS GatewayTLS
+
+	// Denylist blocks content on every hostname served by this gateway.
+	Denylist GatewayDenylist
+
 	// PublicGateways configures behavior of known public gateways.
-	// Each key is a fully qualified domain name (FQDN).
+	// Each key is a fully qualified domain name (FQDN) or a wildcard
+	// pattern such as `*.gw.example.com`. See HostSpec for how the
+	// `Host` HTTP header is matched against the keys.
 	PublicGateways map[string]*GatewaySpec
 }
+
+// HostSpec returns the effective GatewaySpec for the given `Host` HTTP header
+// value. The returned spec is a copy with HTTPHeaders merged over the
+// gateway's EffectiveHTTPHeaders and NoFetch resolved against
+// Gateway.NoFetch.
+//
+// Hostnames are matched with the following precedence:
+//
+//  1. an exact key, e.g. `gw.example.com`;
+//  2. a subdomain gateway, e.g. `<cid>.ipfs.gw.example.com` for the key
+//     `gw.example.com` with UseSubdomains set;
+//  3. a wildcard key matching exactly one leading label, the longest
+//     wildcard winning, e.g. `*.gw.example.com` before `*.example.com`.
+//
+// Keys are matched ignoring case and a trailing dot. The first step that
+// matches decides: a key set to null disables the public gateway for that
+// hostname rather than falling through to the next step. If no key matches,
+// or the matching key is null, ok is false and the spec only carries the
+// global settings.
+func (g *Gateway) HostSpec(host string) (spec *GatewaySpec, ok bool) {
+	host = normalizeGatewayHost(host)
+	if match, found := g.exactHost(host); found {
+		return g.hostSpecResult(match)
+	}
+
+	for _, ns := range []string{".ipfs.", ".ipns."} {
+		idx := strings.Index(host, ns)
+		if idx <= 0 {
+			continue
+		}
+		match, found := g.exactHost(host[idx+len(ns):])
+		if !found {
+			match, found = g.wildcardHost(host[idx+len(ns):])
+		}
+		if !found {
+			continue
+		}
+		if match == nil || match.UseSubdomains {
+			return g.hostSpecResult(match)
+		}
+	}
+
+	if match, found := g.wildcardHost(host); found {
+		return g.hostSpecResult(match)
+	}
+	return g.hostSpecResult(nil)
+}
+
+// hostSpecResult returns the effective spec for a matched key, or the global
+// settings when the key is null or nothing matched.
+func (g *Gateway) hostSpecResult(match *GatewaySpec) (*GatewaySpec, bool) {
+	if match == nil {
+		return g.effectiveSpec(&GatewaySpec{NoDNSLink: g.NoDNSLink}), false
+	}
+	return g.effectiveSpec(match), true
+}
+
+// exactHost finds the spec whose key is the hostname, ignoring case and a
+// trailing dot in the key.
+func (g *Gateway) exactHost(host string) (*GatewaySpec, bool) {
+	if spec, ok := g.PublicGateways[host]; ok {
+		return spec, true
+	}
+	for key, spec := range g.PublicGateways {
+		if !strings.HasPrefix(key, "*.") && normalizeGatewayHost(key) == host {
+			return spec, true
+		}
+	}
+	return nil, false
+}
+
+// wildcardHost finds the spec of the longest wildcard key matching exactly
+// one leading label of the hostname.
+func (g *Gateway) wildcardHost(host string) (*GatewaySpec, bool) {
+	var (
+		best     *GatewaySpec
+		bestLen  = -1
+		foundAny bool
+	)
+	for key, spec := range g.PublicGateways {
+		if !strings.HasPrefix(key, "*.") {
+			continue
+		}
+		suffix := "." + normalizeGatewayHost(key[2:])
+		if !strings.HasSuffix(host, suffix) {
+			continue
+		}
+		label := host[:len(host)-len(suffix)]
+		if label == "" || strings.Contains(label, ".") {
+			continue
+		}
+		if len(suffix) > bestLen {
+			best, bestLen, foundAny = spec, len(suffix), true
+		}
+	}
+	return best, foundAny
+}
+
+func (g *Gateway) effectiveSpec(spec *GatewaySpec) *GatewaySpec {
+	out := *spec
+	out.HTTPHeaders = g.EffectiveHTTPHeaders()
+	for k, v := range spec.HTTPHeaders {
+		out.HTTPHeaders[k] = v
+	}
+	if out.NoFetch == Default {
+		out.NoFetch = False
+		if g.NoFetch {
+			out.NoFetch = True
+		}
+	}
+	return &out
+}
+
+func normalizeGatewayHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// AllowsPath reports whether the content path, e.g. `/ipfs/<cid>/a/b`, may be
+// served according to Allowlist and Denylist. Paths that are not clean, e.g.
+// with "..", "." or empty segments, are never allowed.
+func (s *GatewaySpec) AllowsPath(contentPath string) bool {
+	segments, ok := commandSegments(contentPath)
+	if !ok {
+		return false
+	}
+	for _, entry := range s.Denylist {
+		if gatewayListMatch(entry, segments) {
+			return false
+		}
+	}
+	if len(s.Allowlist) == 0 {
+		return true
+	}
+	for _, entry := range s.Allowlist {
+		if gatewayListMatch(entry, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayListMatch matches an Allowlist or Denylist entry against the
+// segments of a content path. CIDs are compared by multihash so that CIDv0 and
+// CIDv1 forms of the same content match; paths match on whole segments.
+func gatewayListMatch(entry string, segments []string) bool {
+	if !strings.HasPrefix(entry, "/") {
+		if len(segments) < 2 || segments[0] != "ipfs" {
+			return false
+		}
+		return sameCID(entry, segments[1])
+	}
+
+	entrySegments := strings.Split(strings.Trim(entry, "/"), "/")
+	if len(entrySegments) > len(segments) {
+		return false
+	}
+	for i, seg := range entrySegments {
+		if i == 1 && entrySegments[0] == "ipfs" {
+			if !sameCID(seg, segments[i]) {
+				return false
+			}
+			continue
+		}
+		if seg != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameCID(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ca, err := cid.Decode(a)
+	if err != nil {
+		return false
+	}
+	cb, err := cid.Decode(b)
+	if err != nil {
+		return false
+	}
+	return string(ca.Hash()) == string(cb.Hash())
+}
+
+// EffectiveHTTPHeaders returns HTTPHeaders with the CORS section rendered
+// into it.
+func (g *Gateway) EffectiveHTTPHeaders() map[string][]string {
+	return withCORSHeaders(g.HTTPHeaders, g.CORS)
+}
+
+// Validate checks the public gateway hostnames and their policies.
+func (g *Gateway) Validate() error {
+	if g.CORS != nil {
+		if err := g.CORS.Validate(); err != nil {
+			return fmt.Errorf("CORS: %s", err)
+		}
+	}
+	if err := validateAPICommands(g.APICommands); err != nil {
+		return fmt.Errorf("APICommands: %s", err)
+	}
+	if err := g.TLS.Validate(g.PublicGateways); err != nil {
+		return fmt.Errorf("TLS: %s", err)
+	}
+	if err := g.Denylist.Validate(); err != nil {
+		return fmt.Errorf("Denylist: %s", err)
+	}
+
+	hosts := make([]string, 0, len(g.PublicGateways))
+	for host := range g.PublicGateways {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	normalized := make(map[string]string, len(hosts))
+	for _, host := range hosts {
+		if strings.Contains(strings.TrimPrefix(host, "*."), "*") || host == "*." {
+			return fmt.Errorf("PublicGateways: invalid hostname pattern: %s", host)
+		}
+		key := normalizeGatewayHost(host)
+		if strings.HasPrefix(host, "*.") {
+			key = "*." + normalizeGatewayHost(host[2:])
+		}
+		if other, ok := normalized[key]; ok {
+			return fmt.Errorf("PublicGateways: %s and %s name the same host", other, host)
+		}
+		normalized[key] = host
+		spec := g.PublicGateways[host]
+		if spec == nil {
+			continue
+		}
+		if rl := spec.RateLimit; rl != nil && (rl.RequestsPerMinute <= 0 || rl.Burst < 0) {
+			return fmt.Errorf("PublicGateways[%s]: RateLimit must be positive", host)
+		}
+		if spec.MaxResponseSize < 0 {
+			return fmt.Errorf("PublicGateways[%s]: MaxResponseSize must not be negative", host)
+		}
+		for _, entry := range append(append([]string{}, spec.Allowlist...), spec.Denylist...) {
+			if err := validateGatewayListEntry(entry); err != nil {
+				return fmt.Errorf("PublicGateways[%s]: %s", host, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateGatewayListEntry(entry string) error {
+	if !strings.HasPrefix(entry, "/") {
+		if _, err := cid.Decode(entry); err != nil {
+			return fmt.Errorf("invalid CID %q: %s", entry, err)
+		}
+		return nil
+	}
+	if !strings.HasPrefix(entry, "/ipfs/") && !strings.HasPrefix(entry, "/ipns/") {
+		return fmt.Errorf("invalid content path %q: must start with /ipfs/ or /ipns/", entry)
+	}
+	if _, ok := commandSegments(entry); !ok {
+		return fmt.Errorf("invalid content path %q: must be clean", entry)
+	}
+	return nil
+}
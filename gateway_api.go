@@ -0,0 +1,250 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// APIPathPrefix is the URL path prefix of API commands.
+const APIPathPrefix = "/api/v1/"
+
+// sensitiveAPICommands are top-level commands that are never exposed through
+// a wildcard. A rule must name them explicitly, e.g. "config/show".
+var sensitiveAPICommands = map[string]struct{}{
+	"bootstrap": {},
+	"config":    {},
+	"diag":      {},
+	"key":       {},
+	"log":       {},
+	"p2p":       {},
+	"repo":      {},
+	"shutdown":  {},
+	"storage":   {},
+	"swarm":     {},
+	"wallet":    {},
+}
+
+// APICommandRule exposes an API command through the gateway.
+//
+// In the config file, a rule may also be written as a plain string, which is
+// equivalent to a rule with only Command set.
+type APICommandRule struct {
+	// Command is the command path without the /api/v1/ prefix, e.g. "cat"
+	// or "files/stat". A trailing "*" segment, as in "files/*", matches
+	// one or more path segments.
+	Command string
+
+	// Methods lists the allowed HTTP methods. Defaults to POST.
+	Methods []string `json:",omitempty"`
+
+	// Args restricts the positional arguments (the `arg` query
+	// parameters). A pattern ending in "*" matches by prefix, any other
+	// pattern must match exactly. Any argument is allowed when empty.
+	Args []string `json:",omitempty"`
+
+	// MaxArgs limits the number of positional arguments. Zero means no
+	// limit.
+	MaxArgs int `json:",omitempty"`
+
+	// Options restricts the other query parameters to the listed option
+	// names, each with a list of value patterns in the same form as Args.
+	// An empty list of patterns allows any value. When nil, all options
+	// are allowed.
+	Options map[string][]string `json:",omitempty"`
+}
+
+type apiCommandRule APICommandRule
+
+// UnmarshalJSON conforms to the json.Unmarshaler interface.
+func (r *APICommandRule) UnmarshalJSON(data []byte) error {
+	var command string
+	if err := json.Unmarshal(data, &command); err == nil {
+		*r = APICommandRule{Command: command}
+		return nil
+	}
+	return json.Unmarshal(data, (*apiCommandRule)(r))
+}
+
+// MarshalJSON conforms to the json.Marshaler interface.
+func (r APICommandRule) MarshalJSON() ([]byte, error) {
+	if len(r.Methods) == 0 && len(r.Args) == 0 && r.MaxArgs == 0 && r.Options == nil {
+		return json.Marshal(r.Command)
+	}
+	return json.Marshal(apiCommandRule(r))
+}
+
+var _ json.Unmarshaler = (*APICommandRule)(nil)
+var _ json.Marshaler = (*APICommandRule)(nil)
+
+// commandSegments splits a command path into its segments. Paths that are
+// not clean, i.e. with empty, "." or ".." segments, are rejected rather than
+// resolved, so that "files/../shutdown" cannot match "files/*".
+func commandSegments(command string) ([]string, bool) {
+	command = "/" + strings.TrimSuffix(strings.TrimPrefix(command, "/"), "/")
+	if command == "/" || path.Clean(command) != command {
+		return nil, false
+	}
+	return strings.Split(command[1:], "/"), true
+}
+
+// commandMatches reports whether a command path matches a pattern such as
+// "cat" or "files/*".
+func commandMatches(pattern, command string) bool {
+	segments, ok := commandSegments(command)
+	if !ok {
+		return false
+	}
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	for i, seg := range patternSegments {
+		if seg == "*" && i == len(patternSegments)-1 {
+			return len(segments) > i
+		}
+		if i >= len(segments) || seg != segments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(segments)
+}
+
+func argMatches(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	}
+	return pattern == value
+}
+
+func anyArgMatches(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if argMatches(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether the rule permits the request.
+func (r *APICommandRule) allows(method, command string, query url.Values) bool {
+	if !commandMatches(r.Command, command) {
+		return false
+	}
+	top := strings.SplitN(strings.Trim(command, "/"), "/", 2)[0]
+	if _, ok := sensitiveAPICommands[top]; ok {
+		if strings.SplitN(strings.Trim(r.Command, "/"), "/", 2)[0] != top {
+			return false
+		}
+	}
+
+	methods := r.Methods
+	if len(methods) == 0 {
+		methods = []string{http.MethodPost}
+	}
+	methodAllowed := false
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			methodAllowed = true
+			break
+		}
+	}
+	if !methodAllowed {
+		return false
+	}
+
+	args := query["arg"]
+	if r.MaxArgs > 0 && len(args) > r.MaxArgs {
+		return false
+	}
+	if len(r.Args) > 0 {
+		for _, arg := range args {
+			if !anyArgMatches(r.Args, arg) {
+				return false
+			}
+		}
+	}
+
+	if r.Options != nil {
+		for name, values := range query {
+			if name == "arg" {
+				continue
+			}
+			patterns, ok := r.Options[name]
+			if !ok {
+				return false
+			}
+			if len(patterns) == 0 {
+				continue
+			}
+			for _, v := range values {
+				if !anyArgMatches(patterns, v) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// AllowsAPICommand reports whether the gateway may serve the API request with
+// the given HTTP method, URL path (e.g. `/api/v1/cat`) and query parameters.
+// A request is allowed when any rule in APICommands permits it; nothing is
+// allowed when APICommands is empty.
+func (g *Gateway) AllowsAPICommand(method, urlPath string, query url.Values) bool {
+	if !strings.HasPrefix(urlPath, APIPathPrefix) {
+		return false
+	}
+	if _, ok := commandSegments(urlPath); !ok {
+		return false
+	}
+	command := strings.TrimPrefix(urlPath, APIPathPrefix)
+	if command == "" {
+		return false
+	}
+	for i := range g.APICommands {
+		if g.APICommands[i].allows(method, command, query) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateAPICommands checks the rules for malformed commands and methods.
+// validateCommandPattern checks a command pattern such as "cat" or
+// "files/*". Patterns must be clean and may only end with a "*" segment.
+func validateCommandPattern(pattern string) error {
+	segments, ok := commandSegments(pattern)
+	if !ok {
+		if strings.Trim(pattern, "/") == "" {
+			return fmt.Errorf("empty command")
+		}
+		return fmt.Errorf("invalid command pattern: %s", pattern)
+	}
+	for i, seg := range segments {
+		if strings.Contains(seg, "*") && (seg != "*" || i != len(segments)-1) {
+			return fmt.Errorf("invalid command pattern: %s", pattern)
+		}
+	}
+	return nil
+}
+
+func validateAPICommands(rules []APICommandRule) error {
+	for _, r := range rules {
+		if err := validateCommandPattern(r.Command); err != nil {
+			return err
+		}
+		for _, m := range r.Methods {
+			switch strings.ToUpper(m) {
+			case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+				http.MethodPatch, http.MethodDelete, http.MethodOptions:
+			default:
+				return fmt.Errorf("command %s: invalid HTTP method: %s", r.Command, m)
+			}
+		}
+		if r.MaxArgs < 0 {
+			return fmt.Errorf("command %s: MaxArgs must not be negative", r.Command)
+		}
+	}
+	return nil
+}
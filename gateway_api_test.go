@@ -0,0 +1,85 @@
+package config
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+)
+
+func TestAPICommandRuleJSON(t *testing.T) {
+	var g Gateway
+	input := `{"APICommands":["cat",{"Command":"files/*","Methods":["GET","POST"]}]}`
+	if err := json.Unmarshal([]byte(input), &g); err != nil {
+		t.Fatal(err)
+	}
+	if len(g.APICommands) != 2 || g.APICommands[0].Command != "cat" || len(g.APICommands[1].Methods) != 2 {
+		t.Fatalf("unexpected rules: %+v", g.APICommands)
+	}
+
+	out, err := json.Marshal(g.APICommands)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `["cat",{"Command":"files/*","Methods":["GET","POST"]}]`
+	if string(out) != expected {
+		t.Fatalf("expected %s, got %s", expected, out)
+	}
+}
+
+func TestAllowsAPICommand(t *testing.T) {
+	g := Gateway{APICommands: []APICommandRule{
+		{Command: "cat", Args: []string{"/btfs/*"}, MaxArgs: 1, Options: map[string][]string{"length": nil}},
+		{Command: "files/*", Methods: []string{"GET"}},
+		{Command: "*", Methods: []string{"PUT"}},
+		{Command: "config/show"},
+	}}
+
+	for _, tc := range []struct {
+		method, path, query string
+		allowed             bool
+	}{
+		{"POST", "/api/v1/cat", "arg=/btfs/QmFoo&length=10", true},
+		{"POST", "/api/v1/cat", "arg=/btns/example.org", false},
+		{"POST", "/api/v1/cat", "arg=/btfs/a&arg=/btfs/b", false},
+		{"POST", "/api/v1/cat", "arg=/btfs/a&offset=1", false},
+		{"GET", "/api/v1/files/stat", "arg=/", true},
+		{"GET", "/api/v1/files", "", false},
+		{"GET", "/api/v1/version", "", false},
+		{"PUT", "/api/v1/version", "", true},
+		{"POST", "/api/v1/config/show", "", true},
+		{"POST", "/api/v1/config", "arg=Identity.PrivKey", false},
+		{"PUT", "/api/v1/key/list", "", false},
+		{"PUT", "/api/v0/version", "", false},
+		{"GET", "/api/v1/files/stat/", "", true},
+		{"GET", "/api/v1/files/../shutdown", "", false},
+		{"GET", "/api/v1/files/../config/show", "", false},
+		{"PUT", "/api/v1/files/../key/list", "", false},
+		{"PUT", "/api/v1/../v1/key/list", "", false},
+		{"GET", "/api/v1/files/./stat", "", false},
+		{"GET", "/api/v1/files//stat", "", false},
+		{"GET", "/api/v1//files/stat", "", false},
+	} {
+		query, err := url.ParseQuery(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if g.AllowsAPICommand(tc.method, tc.path, query) != tc.allowed {
+			t.Fatalf("%s %s?%s: expected allowed=%v", tc.method, tc.path, tc.query, tc.allowed)
+		}
+	}
+
+	var empty Gateway
+	if empty.AllowsAPICommand("POST", "/api/v1/cat", nil) {
+		t.Fatal("expected no command to be exposed by default")
+	}
+
+	for _, rules := range [][]APICommandRule{
+		{{Command: ""}},
+		{{Command: "files/*/ls"}},
+		{{Command: "cat", Methods: []string{"FETCH"}}},
+	} {
+		if err := validateAPICommands(rules); err == nil {
+			t.Fatalf("expected %+v to be rejected", rules)
+		}
+	}
+}
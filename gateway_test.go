@@ -0,0 +1,136 @@
+package config
+
+import (
+	"testing"
+
+	cid "github.com/ipfs/go-cid"
+)
+
+func TestGatewayHostSpecPrecedence(t *testing.T) {
+	exact := &GatewaySpec{Paths: []string{"/exact"}}
+	subdomain := &GatewaySpec{Paths: []string{"/ipfs"}, UseSubdomains: true}
+	wildcard := &GatewaySpec{Paths: []string{"/wildcard"}}
+	longWildcard := &GatewaySpec{Paths: []string{"/long-wildcard"}, NoFetch: False}
+
+	g := &Gateway{
+		NoFetch:     true,
+		HTTPHeaders: map[string][]string{"Access-Control-Allow-Origin": {"*"}, "X-Global": {"1"}},
+		PublicGateways: map[string]*GatewaySpec{
+			"gw.example.com":       exact,
+			"dweb.example.com":     subdomain,
+			"*.example.com":        wildcard,
+			"*.gw.example.com":     longWildcard,
+			"disabled.example.com": nil,
+			"Upper.Example.com.":   exact,
+
+			// the subdomain step comes before wildcards, and a null
+			// exact key is final
+			"*.ipfs.dweb.example.com":       wildcard,
+			"blocked.ipfs.dweb.example.com": nil,
+		},
+	}
+	g.PublicGateways["gw.example.com"].HTTPHeaders = map[string][]string{
+		"Access-Control-Allow-Origin": {"https://app.example.com"},
+	}
+
+	for _, tc := range []struct {
+		host  string
+		paths string
+		ok    bool
+	}{
+		{"gw.example.com", "/exact", true},
+		{"GW.Example.com:8080", "/exact", true},
+		{"bafy.ipfs.dweb.example.com", "/ipfs", true},
+		{"foo.example.com", "/wildcard", true},
+		{"foo.gw.example.com", "/long-wildcard", true},
+		{"a.b.example.com", "", false},
+		{"disabled.example.com", "", false},
+		{"upper.example.com", "/exact", true},
+		{"blocked.ipfs.dweb.example.com", "", false},
+		{"example.org", "", false},
+	} {
+		spec, ok := g.HostSpec(tc.host)
+		if ok != tc.ok {
+			t.Fatalf("%s: expected ok=%v, got %v", tc.host, tc.ok, ok)
+		}
+		if !ok {
+			continue
+		}
+		if len(spec.Paths) != 1 || spec.Paths[0] != tc.paths {
+			t.Fatalf("%s: expected spec with %s, got %v", tc.host, tc.paths, spec.Paths)
+		}
+	}
+
+	spec, _ := g.HostSpec("gw.example.com")
+	if spec.HTTPHeaders["Access-Control-Allow-Origin"][0] != "https://app.example.com" {
+		t.Fatal("expected per-host header to override the global header")
+	}
+	if spec.HTTPHeaders["X-Global"][0] != "1" {
+		t.Fatal("expected global header to be inherited")
+	}
+	if spec.NoFetch != True {
+		t.Fatal("expected NoFetch to be inherited from the gateway")
+	}
+	if spec, _ = g.HostSpec("foo.gw.example.com"); spec.NoFetch != False {
+		t.Fatal("expected per-host NoFetch to override the gateway")
+	}
+	if exact.NoFetch != Default {
+		t.Fatal("HostSpec must not modify the configured spec")
+	}
+}
+
+func TestGatewaySpecAllowsPath(t *testing.T) {
+	const v0 = "QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+	c, err := cid.Decode(v0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1 := cid.NewCidV1(cid.DagProtobuf, c.Hash()).String()
+
+	spec := &GatewaySpec{Denylist: []string{v0}}
+	if spec.AllowsPath("/ipfs/" + v1 + "/index.html") {
+		t.Fatal("expected CIDv1 form of a denied CIDv0 to be blocked")
+	}
+
+	spec = &GatewaySpec{
+		Allowlist: []string{"/ipfs/" + v1 + "/docs", "/ipns/example.org"},
+		Denylist:  []string{"/ipfs/" + v1 + "/docs/secret"},
+	}
+	for path, allowed := range map[string]bool{
+		"/ipfs/" + v0 + "/docs/a.html":                 true,
+		"/ipfs/" + v0 + "/docsx":                       false,
+		"/ipfs/" + v0 + "/docs/secret/x":               false,
+		"/ipns/example.org/page":                       true,
+		"/ipns/example.com":                            false,
+		"/ipfs/" + v0 + "/docs/x/../secret":            false,
+		"/ipfs/" + v0 + "/docs/./secret":               false,
+		"/ipfs/" + v0 + "/docs//secret":                false,
+		"/ipfs/" + v0 + "/docs/../../ipns/example.com": false,
+	} {
+		if spec.AllowsPath(path) != allowed {
+			t.Fatalf("%s: expected allowed=%v", path, allowed)
+		}
+	}
+
+	g := &Gateway{PublicGateways: map[string]*GatewaySpec{"gw.example.com": {Denylist: []string{"notacid"}}}}
+	if err := g.Validate(); err == nil {
+		t.Fatal("expected invalid list entry to be rejected")
+	}
+	g.PublicGateways = map[string]*GatewaySpec{"gw.*.example.com": {}}
+	if err := g.Validate(); err == nil {
+		t.Fatal("expected invalid wildcard to be rejected")
+	}
+	g.PublicGateways = map[string]*GatewaySpec{"gw.example.com": {Denylist: []string{"/ipfs/" + v0 + "/docs/../x"}}}
+	if err := g.Validate(); err == nil {
+		t.Fatal("expected unclean list entry to be rejected")
+	}
+	for _, keys := range [][]string{
+		{"gw.example.com", "GW.example.com."},
+		{"*.example.com", "*.Example.COM"},
+	} {
+		g.PublicGateways = map[string]*GatewaySpec{keys[0]: {}, keys[1]: {NoFetch: True}}
+		if err := g.Validate(); err == nil {
+			t.Fatalf("expected %v to be rejected as the same host", keys)
+		}
+	}
+}
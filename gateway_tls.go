@@ -0,0 +1,183 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultACMEDirectoryURL is the Let's Encrypt production ACME directory.
+const DefaultACMEDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+
+// DefaultHSTSMaxAge is the default max-age of the Strict-Transport-Security
+// header.
+const DefaultHSTSMaxAge = 365 * 24 * time.Hour
+
+// GatewayTLS configures the HTTPS gateway listening on Addresses.GatewayHTTPS.
+// Either a certificate and key or ACME must be configured.
+type GatewayTLS struct {
+	// CertFile and KeyFile are the paths to a PEM encoded certificate
+	// chain and private key.
+	CertFile string `json:",omitempty"`
+	KeyFile  string `json:",omitempty"`
+
+	// ACME obtains certificates automatically instead.
+	ACME *GatewayACME `json:",omitempty"`
+
+	// RedirectHTTP permanently redirects requests on the plain HTTP
+	// gateway to the HTTPS gateway.
+	RedirectHTTP bool `json:",omitempty"`
+
+	// HSTS sends the Strict-Transport-Security header on HTTPS responses.
+	HSTS *GatewayHSTS `json:",omitempty"`
+}
+
+// GatewayACME configures automatic certificate management.
+type GatewayACME struct {
+	// DirectoryURL is the ACME directory. Defaults to Let's Encrypt.
+	DirectoryURL string `json:",omitempty"`
+
+	// Email is the contact address registered with the ACME account.
+	Email string
+
+	// Domains lists the hostnames to obtain certificates for. Each must be
+	// a key of Gateway.PublicGateways. Defaults to all non-wildcard keys.
+	Domains []string `json:",omitempty"`
+}
+
+// GatewayHSTS configures the Strict-Transport-Security header.
+type GatewayHSTS struct {
+	// MaxAge defaults to one year.
+	MaxAge            Duration `json:",omitempty"`
+	IncludeSubdomains bool     `json:",omitempty"`
+	Preload           bool     `json:",omitempty"`
+}
+
+// Enabled reports whether TLS is configured.
+func (t *GatewayTLS) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.ACME != nil
+}
+
+// Header returns the value of the Strict-Transport-Security header.
+func (h *GatewayHSTS) Header() string {
+	maxAge := time.Duration(h.MaxAge)
+	if maxAge == 0 {
+		maxAge = DefaultHSTSMaxAge
+	}
+	value := fmt.Sprintf("max-age=%d", int64(maxAge/time.Second))
+	if h.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if h.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+// ACMEDomains returns the hostnames ACME should obtain certificates for.
+func (g *Gateway) ACMEDomains() []string {
+	if g.TLS.ACME == nil {
+		return nil
+	}
+	if len(g.TLS.ACME.Domains) > 0 {
+		return g.TLS.ACME.Domains
+	}
+	var domains []string
+	for host, spec := range g.PublicGateways {
+		if spec != nil && !strings.HasPrefix(host, "*.") {
+			domains = append(domains, host)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Warnings reports an expired certificate. Expiry is not a Validate error so
+// that a node with an outdated certificate still starts and can be fixed.
+func (t *GatewayTLS) Warnings() []string {
+	if t.CertFile == "" {
+		return nil
+	}
+	pair, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return []string{fmt.Sprintf("certificate %s expired on %s", t.CertFile, leaf.NotAfter.Format(time.RFC3339))}
+	}
+	return nil
+}
+
+// Validate checks that the certificate files exist and match, and that the
+// ACME domains line up with the public gateways.
+func (t *GatewayTLS) Validate(publicGateways map[string]*GatewaySpec) error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("CertFile and KeyFile must be set together")
+	}
+	if t.CertFile != "" && t.ACME != nil {
+		return fmt.Errorf("CertFile and ACME are mutually exclusive")
+	}
+	if !t.Enabled() {
+		if t.RedirectHTTP {
+			return fmt.Errorf("RedirectHTTP requires a certificate or ACME")
+		}
+		if t.HSTS != nil {
+			return fmt.Errorf("HSTS requires a certificate or ACME")
+		}
+		return nil
+	}
+
+	if t.CertFile != "" {
+		pair, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return err
+		}
+		if _, err := x509.ParseCertificate(pair.Certificate[0]); err != nil {
+			return err
+		}
+	}
+
+	if acme := t.ACME; acme != nil {
+		if acme.DirectoryURL != "" {
+			u, err := url.Parse(acme.DirectoryURL)
+			if err != nil || u.Scheme != "https" || u.Host == "" {
+				return fmt.Errorf("invalid ACME directory URL: %s", acme.DirectoryURL)
+			}
+		}
+		if acme.Email != "" && !strings.Contains(acme.Email, "@") {
+			return fmt.Errorf("invalid ACME email: %s", acme.Email)
+		}
+		g := Gateway{TLS: *t, PublicGateways: publicGateways}
+		domains := g.ACMEDomains()
+		if len(domains) == 0 {
+			return fmt.Errorf("ACME requires at least one public gateway hostname")
+		}
+		for _, domain := range domains {
+			if strings.Contains(domain, "*") {
+				return fmt.Errorf("ACME domain %s: wildcard certificates are not supported", domain)
+			}
+			if spec, ok := publicGateways[domain]; !ok || spec == nil {
+				return fmt.Errorf("ACME domain %s is not configured in PublicGateways", domain)
+			}
+		}
+	}
+
+	if h := t.HSTS; h != nil {
+		if h.MaxAge < 0 {
+			return fmt.Errorf("HSTS.MaxAge must not be negative")
+		}
+		// https://hstspreload.org/#submission-requirements
+		if h.Preload && (!h.IncludeSubdomains || (h.MaxAge != 0 && time.Duration(h.MaxAge) < DefaultHSTSMaxAge)) {
+			return fmt.Errorf("HSTS.Preload requires IncludeSubdomains and a MaxAge of at least one year")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestCert issues a certificate for host from a throwaway local CA and
+// writes the certificate and key to dir.
+func writeTestCert(t *testing.T, dir, host string, notAfter time.Time) (certFile, keyFile string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, host+".crt")
+	keyFile = filepath.Join(dir, host+".key")
+	certPEM := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})...)
+	if err := ioutil.WriteFile(certFile, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := ioutil.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestGatewayTLSCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gateway-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir, "gw.example.com", time.Now().Add(time.Hour))
+	otherCert, otherKey := writeTestCert(t, dir, "other.example.com", time.Now().Add(time.Hour))
+	expiredCert, expiredKey := writeTestCert(t, dir, "expired.example.com", time.Now().Add(-time.Hour))
+
+	tlsCfg := GatewayTLS{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		RedirectHTTP: true,
+		HSTS:         &GatewayHSTS{IncludeSubdomains: true, Preload: true},
+	}
+	if err := tlsCfg.Validate(nil); err != nil {
+		t.Fatal(err)
+	}
+	if w := tlsCfg.Warnings(); len(w) != 0 {
+		t.Fatalf("unexpected warnings: %v", w)
+	}
+
+	tlsCfg.KeyFile = otherKey
+	if err := tlsCfg.Validate(nil); err == nil {
+		t.Fatal("expected mismatched key to be rejected")
+	}
+
+	tlsCfg = GatewayTLS{CertFile: otherCert, KeyFile: filepath.Join(dir, "missing.key")}
+	if err := tlsCfg.Validate(nil); err == nil {
+		t.Fatal("expected missing key file to be rejected")
+	}
+
+	tlsCfg = GatewayTLS{CertFile: expiredCert, KeyFile: expiredKey}
+	if err := tlsCfg.Validate(nil); err != nil {
+		t.Fatalf("expected expired certificate to pass Validate: %s", err)
+	}
+	if w := tlsCfg.Warnings(); len(w) != 1 || !strings.Contains(w[0], "expired") {
+		t.Fatalf("expected an expiry warning, got %v", w)
+	}
+}
+
+func TestGatewayTLSACME(t *testing.T) {
+	g := Gateway{
+		TLS: GatewayTLS{ACME: &GatewayACME{Email: "ops@example.com"}},
+		PublicGateways: map[string]*GatewaySpec{
+			"gw.example.com":   {},
+			"b.example.com":    {},
+			"*.example.com":    {},
+			"null.example.com": nil,
+		},
+	}
+	domains := g.ACMEDomains()
+	if len(domains) != 2 || domains[0] != "b.example.com" || domains[1] != "gw.example.com" {
+		t.Fatalf("unexpected ACME domains: %v", domains)
+	}
+	if err := g.TLS.Validate(g.PublicGateways); err != nil {
+		t.Fatal(err)
+	}
+
+	g.TLS.ACME.Domains = []string{"gw.example.com", "unknown.example.com"}
+	if err := g.TLS.Validate(g.PublicGateways); err == nil {
+		t.Fatal("expected ACME domain missing from PublicGateways to be rejected")
+	}
+
+	if err := (&GatewayTLS{RedirectHTTP: true}).Validate(nil); err == nil {
+		t.Fatal("expected redirect without TLS to be rejected")
+	}
+
+	h := GatewayHSTS{IncludeSubdomains: true}
+	if h.Header() != "max-age=31536000; includeSubDomains" {
+		t.Fatalf("unexpected HSTS header: %s", h.Header())
+	}
+}
@@ -2,11 +2,13 @@ module github.com/TRON-US/go-btfs-config
 
 require (
 	github.com/facebookgo/atomicfile v0.0.0-20151019160806-2de1f203e7d5
-	github.com/ipfs/go-cid v0.0.6 // indirect
+	github.com/ipfs/go-cid v0.0.6
 	github.com/libp2p/go-libp2p-core v0.6.0
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/multiformats/go-multiaddr v0.2.2
+	github.com/multiformats/go-multihash v0.0.13
 	github.com/tron-us/go-btfs-common v0.2.11
+	golang.org/x/crypto v0.0.0-20191029031824-8986dd9e96cf
 )
 
 go 1.14
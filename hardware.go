@@ -0,0 +1,235 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kiB = 1 << 10
+	miB = 1 << 20
+	giB = 1 << 30
+)
+
+// HostResources describes the machine a node runs on, as used by the "auto"
+// profile. Zero values mean the resource could not be determined.
+type HostResources struct {
+	CPUs        int
+	TotalMemory uint64 // in bytes
+	FreeDisk    uint64 // in bytes, available under the datastore path
+}
+
+// ReadHostResources reads the resources of the current machine. FreeDisk is
+// read for the filesystem holding path, or its closest existing parent.
+// Resources that are not available on the platform are left zero.
+func ReadHostResources(path string) (HostResources, error) {
+	for {
+		if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+			break
+		}
+		path = filepath.Dir(path)
+	}
+	return readHostResources(path)
+}
+
+// parseMeminfo returns the MemTotal entry of a /proc/meminfo file, in bytes.
+func parseMeminfo(r io.Reader) (uint64, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "MemTotal:" {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid MemTotal: %s", fields[1])
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			v *= kiB
+		}
+		return v, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("MemTotal not found")
+}
+
+// AutoTuneDecision records a setting chosen by AutoTune and why.
+type AutoTuneDecision struct {
+	Setting string
+	Value   string
+	Reason  string
+}
+
+// AutoTuneReport explains the settings chosen by AutoTune.
+type AutoTuneReport struct {
+	Resources HostResources
+	Decisions []AutoTuneDecision
+}
+
+func (r *AutoTuneReport) add(setting, value, reason string, args ...interface{}) {
+	r.Decisions = append(r.Decisions, AutoTuneDecision{
+		Setting: setting,
+		Value:   value,
+		Reason:  fmt.Sprintf(reason, args...),
+	})
+}
+
+func (r *AutoTuneReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "detected %d CPUs, %s memory, %s free disk\n",
+		r.Resources.CPUs, formatBytes(r.Resources.TotalMemory), formatBytes(r.Resources.FreeDisk))
+	for _, d := range r.Decisions {
+		fmt.Fprintf(&b, "%s = %s: %s\n", d.Setting, d.Value, d.Reason)
+	}
+	return b.String()
+}
+
+// autoTuneTier is a class of machines sharing connection manager and
+// reprovider settings.
+type autoTuneTier struct {
+	name        string
+	minCPUs     int
+	minMemory   uint64
+	lowWater    int
+	highWater   int
+	gracePeriod time.Duration
+	reprovide   string
+}
+
+// autoTuneTiers is ordered from the largest to the smallest machines; a
+// machine gets the first tier whose minimums it meets.
+var autoTuneTiers = []autoTuneTier{
+	{"large", 4, 8 * giB, DefaultConnMgrLowWater, DefaultConnMgrHighWater, DefaultConnMgrGracePeriod, "12h"},
+	{"medium", 2, 4 * giB, 300, 500, DefaultConnMgrGracePeriod, "12h"},
+	{"small", 2, 2 * giB, 100, 200, 30 * time.Second, "24h"},
+	{"tiny", 0, 0, 20, 40, time.Minute, "24h"},
+}
+
+const (
+	// autoTuneStorageShare is the share of the free disk given to
+	// StorageMax, in percent.
+	autoTuneStorageShare = 80
+	// autoTuneMinStorage is the StorageMax below which the default is kept.
+	autoTuneMinStorage = 1e9
+	// autoTuneBadgerMemory is the memory needed to pick badger over flatfs.
+	autoTuneBadgerMemory = 8 * giB
+	// autoTuneBloomMemory is the memory needed to enable the bloom filter.
+	autoTuneBloomMemory = 2 * giB
+	// autoTuneBlockSize is the block size assumed to size the bloom filter.
+	autoTuneBlockSize = 256 * kiB
+)
+
+// autoProfile returns the transform of the "auto" profile, reading the
+// resources of the machine with read.
+func autoProfile(read func(path string) (HostResources, error)) Transformer {
+	return func(c *Config) error {
+		path, err := DataStorePath("")
+		if err != nil {
+			return err
+		}
+		res, err := read(path)
+		if err != nil {
+			return fmt.Errorf("reading host resources failed: [%v]", err)
+		}
+		AutoTune(c, res)
+		return nil
+	}
+}
+
+// AutoTune adjusts the connection manager watermarks, the bloom filter size,
+// StorageMax, the reprovider interval and the datastore to the resources of
+// a machine, and reports why each value was chosen. Settings depending on a
+// resource that could not be determined are left unchanged.
+func AutoTune(c *Config, res HostResources) *AutoTuneReport {
+	r := &AutoTuneReport{Resources: res}
+
+	if res.CPUs > 0 && res.TotalMemory > 0 {
+		tier := autoTuneTiers[len(autoTuneTiers)-1]
+		for _, t := range autoTuneTiers {
+			if res.CPUs >= t.minCPUs && res.TotalMemory >= t.minMemory {
+				tier = t
+				break
+			}
+		}
+		c.Swarm.ConnMgr.LowWater = tier.lowWater
+		c.Swarm.ConnMgr.HighWater = tier.highWater
+		c.Swarm.ConnMgr.GracePeriod = tier.gracePeriod.String()
+		reason := fmt.Sprintf("%s machine (%d CPUs, %s memory)", tier.name, res.CPUs, formatBytes(res.TotalMemory))
+		r.add("Swarm.ConnMgr.LowWater", strconv.Itoa(tier.lowWater), reason)
+		r.add("Swarm.ConnMgr.HighWater", strconv.Itoa(tier.highWater), reason)
+		r.add("Swarm.ConnMgr.GracePeriod", c.Swarm.ConnMgr.GracePeriod, reason)
+		c.Reprovider.Interval = tier.reprovide
+		r.add("Reprovider.Interval", tier.reprovide, reason)
+	}
+
+	if res.FreeDisk > 0 {
+		max := res.FreeDisk / 100 * autoTuneStorageShare
+		if max >= autoTuneMinStorage {
+			c.Datastore.StorageMax = fmt.Sprintf("%dGB", max/1e9)
+			r.add("Datastore.StorageMax", c.Datastore.StorageMax,
+				"%d%% of the %s free under the datastore path", autoTuneStorageShare, formatBytes(res.FreeDisk))
+		} else {
+			r.add("Datastore.StorageMax", c.Datastore.StorageMax,
+				"kept, only %s free under the datastore path", formatBytes(res.FreeDisk))
+		}
+	}
+
+	if res.TotalMemory > 0 {
+		if res.TotalMemory < autoTuneBloomMemory {
+			c.Datastore.BloomFilterSize = 0
+			r.add("Datastore.BloomFilterSize", "0", "disabled with less than %s memory", formatBytes(autoTuneBloomMemory))
+		} else if storageMax, err := parseByteSize(c.Datastore.StorageMax); err == nil {
+			// about 10 bits per block keeps false positives near 1%,
+			// capped at 1% of the memory
+			size := storageMax / autoTuneBlockSize * 10 / 8
+			if limit := res.TotalMemory / 100; size > limit {
+				size = limit
+			}
+			c.Datastore.BloomFilterSize = int(floorPowerOfTwo(size))
+			r.add("Datastore.BloomFilterSize", strconv.Itoa(c.Datastore.BloomFilterSize),
+				"10 bits per %s block of StorageMax %s, capped at 1%% of memory",
+				formatBytes(autoTuneBlockSize), c.Datastore.StorageMax)
+		}
+
+		if res.TotalMemory >= autoTuneBadgerMemory && res.CPUs >= 4 {
+			c.Datastore.Spec = badgerSpec()
+			r.add("Datastore.Spec", "badgerds", "enough memory (%s) and CPUs (%d) for badger", formatBytes(res.TotalMemory), res.CPUs)
+		} else {
+			c.Datastore.Spec = flatfsSpec()
+			r.add("Datastore.Spec", "flatfs", "badger needs at least %s memory and 4 CPUs", formatBytes(autoTuneBadgerMemory))
+		}
+	}
+	return r
+}
+
+// floorPowerOfTwo returns the largest power of two not above v, so the
+// memory cap holds, or 0 for 0.
+func floorPowerOfTwo(v uint64) uint64 {
+	if v == 0 {
+		return 0
+	}
+	p := uint64(1)
+	for p <= v/2 {
+		p <<= 1
+	}
+	return p
+}
+
+func formatBytes(v uint64) string {
+	switch {
+	case v >= giB:
+		return fmt.Sprintf("%.1fGiB", float64(v)/giB)
+	case v >= miB:
+		return fmt.Sprintf("%.1fMiB", float64(v)/miB)
+	default:
+		return fmt.Sprintf("%dB", v)
+	}
+}
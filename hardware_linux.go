@@ -0,0 +1,36 @@
+//go:build linux
+// +build linux
+
+package config
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+func readHostResources(path string) (HostResources, error) {
+	res := HostResources{CPUs: runtime.NumCPU()}
+
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return res, err
+	}
+	defer f.Close()
+	if res.TotalMemory, err = parseMeminfo(f); err != nil {
+		return res, err
+	}
+
+	res.FreeDisk, err = diskFree(path)
+	return res, err
+}
+
+// diskFree returns the space available to unprivileged users on the
+// filesystem holding path.
+func diskFree(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
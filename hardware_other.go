@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package config
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// readHostResources only knows the CPU count outside of Linux.
+func readHostResources(path string) (HostResources, error) {
+	return HostResources{CPUs: runtime.NumCPU()}, nil
+}
+
+func diskFree(path string) (uint64, error) {
+	return 0, fmt.Errorf("reading free disk space is not supported on %s", runtime.GOOS)
+}
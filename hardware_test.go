@@ -0,0 +1,91 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseMeminfo(t *testing.T) {
+	meminfo := `MemTotal:        2040316 kB
+MemFree:          181212 kB
+MemAvailable:    1024608 kB
+`
+	total, err := parseMeminfo(strings.NewReader(meminfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2040316*1024 {
+		t.Fatalf("unexpected total memory: %d", total)
+	}
+	if _, err := parseMeminfo(strings.NewReader("MemFree: 1 kB\n")); err == nil {
+		t.Fatal("expected an error without MemTotal")
+	}
+}
+
+func TestAutoTune(t *testing.T) {
+	cfg := &Config{Datastore: DefaultDatastoreConfig()}
+
+	// a Raspberry Pi class machine
+	r := AutoTune(cfg, HostResources{CPUs: 4, TotalMemory: 1 * giB, FreeDisk: 50e9})
+	if cfg.Swarm.ConnMgr.LowWater != 20 || cfg.Swarm.ConnMgr.HighWater != 40 {
+		t.Fatalf("unexpected watermarks: %+v", cfg.Swarm.ConnMgr)
+	}
+	if cfg.Datastore.StorageMax != "40GB" || cfg.Datastore.BloomFilterSize != 0 {
+		t.Fatalf("unexpected datastore settings: %s, %d", cfg.Datastore.StorageMax, cfg.Datastore.BloomFilterSize)
+	}
+	if cfg.Datastore.Spec["type"] != "mount" {
+		t.Fatal("expected flatfs on a small machine")
+	}
+	if len(r.Decisions) == 0 || !strings.Contains(r.String(), "tiny machine") {
+		t.Fatalf("unexpected report:\n%s", r)
+	}
+
+	// a server
+	AutoTune(cfg, HostResources{CPUs: 16, TotalMemory: 64 * giB, FreeDisk: 4e12})
+	if cfg.Swarm.ConnMgr.LowWater != DefaultConnMgrLowWater || cfg.Reprovider.Interval != "12h" {
+		t.Fatalf("unexpected settings for a server: %+v, %s", cfg.Swarm.ConnMgr, cfg.Reprovider.Interval)
+	}
+	if cfg.Datastore.StorageMax != "3200GB" {
+		t.Fatalf("unexpected StorageMax: %s", cfg.Datastore.StorageMax)
+	}
+	// 3.2TB of 256KiB blocks at 10 bits each, rounded down
+	if cfg.Datastore.BloomFilterSize != 8*miB {
+		t.Fatalf("unexpected bloom filter size: %d", cfg.Datastore.BloomFilterSize)
+	}
+	if cfg.Datastore.Spec["child"].(map[string]interface{})["type"] != "badgerds" {
+		t.Fatal("expected badger on a server")
+	}
+
+	// nothing known, nothing changed
+	before := cfg.Datastore.StorageMax
+	if r := AutoTune(cfg, HostResources{}); len(r.Decisions) != 0 || cfg.Datastore.StorageMax != before {
+		t.Fatalf("expected no decisions without resources, got %v", r.Decisions)
+	}
+}
+
+func TestAutoProfile(t *testing.T) {
+	read := func(string) (HostResources, error) {
+		return HostResources{CPUs: 2, TotalMemory: 4 * giB, FreeDisk: 100e9}, nil
+	}
+	cfg := &Config{Datastore: DefaultDatastoreConfig()}
+	if err := autoProfile(read)(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Swarm.ConnMgr.HighWater != 500 || cfg.Datastore.StorageMax != "80GB" {
+		t.Fatalf("unexpected settings: %+v, %s", cfg.Swarm.ConnMgr, cfg.Datastore.StorageMax)
+	}
+
+	failing := func(string) (HostResources, error) { return HostResources{}, errors.New("no /proc") }
+	if err := autoProfile(failing)(cfg); err == nil {
+		t.Fatal("expected the read error to be returned")
+	}
+}
+
+func TestFloorPowerOfTwo(t *testing.T) {
+	for v, want := range map[uint64]uint64{0: 0, 1: 1, 2: 2, 3: 2, 1023: 512, 1024: 1024, 1025: 1024} {
+		if got := floorPowerOfTwo(v); got != want {
+			t.Errorf("floorPowerOfTwo(%d) = %d, want %d", v, got, want)
+		}
+	}
+}
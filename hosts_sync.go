@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	hubpb "github.com/tron-us/go-btfs-common/protos/hub"
+)
+
+// HostsSyncMode is the hub mode storage hosts are synced with. It is stored
+// as the hubpb enum name, such as "SCORE"; the zero value is SCORE.
+type HostsSyncMode hubpb.HostsReq_Mode
+
+// Names that are not hub modes decode to negative modes, so that the config
+// still loads and is saved unchanged; Validate rejects them and migrate_22
+// resets them. unknownHostsSyncModes holds their raw names, mode -1 being the
+// first.
+var (
+	unknownHostsSyncModesMu sync.Mutex
+	unknownHostsSyncModes   []string
+)
+
+// unknownHostsSyncMode returns the mode standing for an unknown name.
+func unknownHostsSyncMode(name string) HostsSyncMode {
+	unknownHostsSyncModesMu.Lock()
+	defer unknownHostsSyncModesMu.Unlock()
+	for i, n := range unknownHostsSyncModes {
+		if n == name {
+			return HostsSyncMode(-1 - i)
+		}
+	}
+	unknownHostsSyncModes = append(unknownHostsSyncModes, name)
+	return HostsSyncMode(-len(unknownHostsSyncModes))
+}
+
+// unknownName returns the raw name an unknown mode was decoded from.
+func (m HostsSyncMode) unknownName() (string, bool) {
+	unknownHostsSyncModesMu.Lock()
+	defer unknownHostsSyncModesMu.Unlock()
+	i := -1 - int(m)
+	if i < 0 || i >= len(unknownHostsSyncModes) {
+		return "", false
+	}
+	return unknownHostsSyncModes[i], true
+}
+
+// HostsSyncModes lists the valid modes.
+func HostsSyncModes() []HostsSyncMode {
+	modes := make([]HostsSyncMode, 0, len(hubpb.HostsReq_Mode_name))
+	for v := range hubpb.HostsReq_Mode_name {
+		modes = append(modes, HostsSyncMode(v))
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i] < modes[j] })
+	return modes
+}
+
+func (m HostsSyncMode) String() string {
+	if name, ok := m.unknownName(); ok {
+		return name
+	}
+	return hubpb.HostsReq_Mode(m).String()
+}
+
+// Valid reports whether m is a known hub mode.
+func (m HostsSyncMode) Valid() bool {
+	_, ok := hubpb.HostsReq_Mode_name[int32(m)]
+	return ok
+}
+
+// MarshalText writes the hubpb enum name, or the raw name an unknown mode was
+// decoded from.
+func (m HostsSyncMode) MarshalText() ([]byte, error) {
+	if name, ok := m.unknownName(); ok {
+		return []byte(name), nil
+	}
+	if !m.Valid() {
+		return nil, fmt.Errorf("unknown hosts sync mode: %d", int32(m))
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText accepts the hubpb enum names, ignoring case and surrounding
+// space. The empty string is SCORE, and other names decode to a mode that is
+// not Valid and is written back unchanged.
+func (m *HostsSyncMode) UnmarshalText(text []byte) error {
+	name := strings.ToUpper(strings.TrimSpace(string(text)))
+	if name == "" {
+		*m = HostsSyncMode(hubpb.HostsReq_SCORE)
+		return nil
+	}
+	v, ok := hubpb.HostsReq_Mode_value[name]
+	if !ok {
+		*m = unknownHostsSyncMode(string(text))
+		return nil
+	}
+	*m = HostsSyncMode(v)
+	return nil
+}
+
+// validateHostsSyncMode reports an unknown mode with the expected names.
+func validateHostsSyncMode(m HostsSyncMode) error {
+	if m.Valid() {
+		return nil
+	}
+	names := make([]string, 0, len(hubpb.HostsReq_Mode_name))
+	for _, mode := range HostsSyncModes() {
+		names = append(names, mode.String())
+	}
+	return fmt.Errorf("unknown hosts sync mode, expected one of %s", strings.Join(names, ", "))
+}
+
+// Defaults of the HostsSync options.
+const (
+	DefaultHostsSyncInterval = 30 * time.Minute
+	DefaultHostsSyncMaxHosts = 1000
+)
+
+// MinHostsSyncInterval is the shortest interval between two syncs.
+const MinHostsSyncInterval = time.Minute
+
+// HostsSyncOptions configures how hosts are synced in a mode.
+type HostsSyncOptions struct {
+	// Interval between two syncs.
+	Interval Duration `json:",omitempty"`
+
+	// MaxHosts is the largest number of hosts kept.
+	MaxHosts int `json:",omitempty"`
+
+	// CachePath is the directory, relative to the repo, the synced hosts
+	// are cached in. Empty keeps them in the datastore.
+	CachePath string `json:",omitempty"`
+}
+
+// HostsSync configures the hosts sync options, with overrides per mode.
+type HostsSync struct {
+	HostsSyncOptions
+
+	// Modes overrides the options above for a mode, keyed by mode name.
+	Modes map[HostsSyncMode]HostsSyncOptions `json:",omitempty"`
+}
+
+// Options returns the options of a mode: its overrides, then the section
+// wide options, then the defaults.
+func (h *HostsSync) Options(mode HostsSyncMode) HostsSyncOptions {
+	o := HostsSyncOptions{
+		Interval: Duration(DefaultHostsSyncInterval),
+		MaxHosts: DefaultHostsSyncMaxHosts,
+	}
+	for _, layer := range []HostsSyncOptions{h.HostsSyncOptions, h.Modes[mode]} {
+		if layer.Interval != 0 {
+			o.Interval = layer.Interval
+		}
+		if layer.MaxHosts != 0 {
+			o.MaxHosts = layer.MaxHosts
+		}
+		if layer.CachePath != "" {
+			o.CachePath = layer.CachePath
+		}
+	}
+	return o
+}
+
+// Validate checks the options and their per-mode overrides.
+func (h *HostsSync) Validate() error {
+	if err := h.HostsSyncOptions.validate(); err != nil {
+		return err
+	}
+	for mode, o := range h.Modes {
+		if err := validateHostsSyncMode(mode); err != nil {
+			return fmt.Errorf("Modes: %s", err)
+		}
+		if err := o.validate(); err != nil {
+			return fmt.Errorf("%s: %s", mode, err)
+		}
+	}
+	return nil
+}
+
+func (o *HostsSyncOptions) validate() error {
+	if o.Interval != 0 && time.Duration(o.Interval) < MinHostsSyncInterval {
+		return fmt.Errorf("Interval must be at least %s", MinHostsSyncInterval)
+	}
+	if o.MaxHosts < 0 {
+		return fmt.Errorf("MaxHosts must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	hubpb "github.com/tron-us/go-btfs-common/protos/hub"
+)
+
+func TestHostsSyncModeText(t *testing.T) {
+	for text, expected := range map[string]hubpb.HostsReq_Mode{
+		`"SCORE"`:     hubpb.HostsReq_SCORE,
+		`""`:          hubpb.HostsReq_SCORE,
+		`"testnet"`:   hubpb.HostsReq_TESTNET,
+		`" speed "`:   hubpb.HostsReq_SPEED,
+		`"GEO"`:       hubpb.HostsReq_GEO,
+		`"REP"`:       hubpb.HostsReq_REP,
+		`"PRICE"`:     hubpb.HostsReq_PRICE,
+		`"TESTNET" `:  hubpb.HostsReq_TESTNET,
+		`  "score"  `: hubpb.HostsReq_SCORE,
+	} {
+		var m HostsSyncMode
+		if err := json.Unmarshal([]byte(text), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m != HostsSyncMode(expected) {
+			t.Errorf("%s: expected %s, got %s", text, expected, m)
+		}
+	}
+	var m HostsSyncMode
+	if err := json.Unmarshal([]byte(`"FASTEST"`), &m); err != nil {
+		t.Fatalf("expected an unknown mode to decode, got %s", err)
+	}
+	if m.Valid() {
+		t.Fatal("expected an unknown mode not to be valid")
+	}
+	e := Experiments{HostsSyncMode: m}
+	if err := e.Validate(); err == nil {
+		t.Fatal("expected an unknown mode to be rejected")
+	}
+	if out, err := json.Marshal(m); err != nil || string(out) != `"FASTEST"` {
+		t.Fatalf("expected an unknown mode to be written back unchanged, got %s, %v", out, err)
+	}
+	var fast HostsSyncMode
+	if err := json.Unmarshal([]byte(`"fastest"`), &fast); err != nil || fast == m {
+		t.Fatal("expected unknown names to keep their case")
+	}
+
+	out, err := json.Marshal(Experiments{HostsSyncMode: HostsSyncMode(hubpb.HostsReq_TESTNET)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw struct{ HostsSyncMode string }
+	if err := json.Unmarshal(out, &raw); err != nil || raw.HostsSyncMode != "TESTNET" {
+		t.Fatalf("unexpected encoding: %s", out)
+	}
+	if _, err := json.Marshal(HostsSyncMode(42)); err == nil {
+		t.Fatal("expected an invalid mode not to be encoded")
+	}
+
+	if modes := HostsSyncModes(); len(modes) != 6 || modes[0] != HostsSyncMode(hubpb.HostsReq_SCORE) {
+		t.Fatalf("unexpected modes: %v", modes)
+	}
+}
+
+func TestHostsSyncOptions(t *testing.T) {
+	var h HostsSync
+	if err := json.Unmarshal([]byte(`{
+		"MaxHosts": 500,
+		"Modes": {"testnet": {"Interval": "5m", "CachePath": "hosts-testnet"}}
+	}`), &h); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	o := h.Options(HostsSyncMode(hubpb.HostsReq_TESTNET))
+	expected := HostsSyncOptions{Interval: Duration(5 * time.Minute), MaxHosts: 500, CachePath: "hosts-testnet"}
+	if o != expected {
+		t.Fatalf("expected %+v, got %+v", expected, o)
+	}
+	o = h.Options(HostsSyncMode(hubpb.HostsReq_SCORE))
+	if o.Interval != Duration(DefaultHostsSyncInterval) || o.MaxHosts != 500 || o.CachePath != "" {
+		t.Fatalf("unexpected options: %+v", o)
+	}
+
+	h.Modes[HostsSyncMode(hubpb.HostsReq_GEO)] = HostsSyncOptions{Interval: Duration(time.Second)}
+	if err := h.Validate(); err == nil {
+		t.Fatal("expected a too short interval to be rejected")
+	}
+	var unknown HostsSync
+	if err := json.Unmarshal([]byte(`{"Modes": {"NEAREST": {}}}`), &unknown); err != nil {
+		t.Fatal(err)
+	}
+	if err := unknown.Validate(); err == nil {
+		t.Fatal("expected an unknown mode key to be rejected")
+	}
+}
@@ -12,6 +12,7 @@ import (
 
 	ci "github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
 )
 
 func Init(out io.Writer, nBitsForKeypair int, keyType string, importKey string, mnemonic string, rmOnUnpin bool) (*Config, error) {
@@ -65,14 +66,19 @@ func Init(out io.Writer, nBitsForKeypair int, keyType string, importKey string,
 			Writable:     false,
 			NoFetch:      false,
 			PathPrefixes: []string{},
-			HTTPHeaders: map[string][]string{
-				"Access-Control-Allow-Origin":  []string{"*"},
-				"Access-Control-Allow-Methods": []string{"GET"},
-				"Access-Control-Allow-Headers": []string{"X-Requested-With", "Range", "User-Agent"},
+			HTTPHeaders:  map[string][]string{},
+			CORS: &CORS{
+				AllowedOrigins: []string{"*"},
+				AllowedMethods: []string{"GET"},
+				AllowedHeaders: []string{"X-Requested-With", "Range", "User-Agent"},
 			},
-			APICommands: []string{},
+			APICommands: []APICommandRule{},
 		},
-		Services: DefaultServicesConfig(),
+		Services:      DefaultServicesConfig(),
+		StorageHost:   DefaultStorageHostConfig(),
+		StorageClient: DefaultStorageClientConfig(),
+		Repair:        DefaultRepairConfig(),
+		Challenge:     DefaultChallengeConfig(),
 		Reprovider: Reprovider{
 			Interval: "12h",
 			Strategy: "all",
@@ -85,14 +91,16 @@ func Init(out io.Writer, nBitsForKeypair int, keyType string, importKey string,
 				GracePeriod: DefaultConnMgrGracePeriod.String(),
 				Type:        "basic",
 			},
-			EnableAutoRelay: DefaultEnableAutoRelay,
+			RelayClient: RelayClient{
+				Enabled: True,
+			},
 		},
 		Experimental: Experiments{
 			Libp2pStreamMounting: true, // Enabled for remote api
 			StorageClientEnabled: true,
 			RemoveOnUnpin:        rmOnUnpin,
 			HostsSyncEnabled:     DefaultHostsSyncEnabled,
-			HostsSyncMode:        DefaultHostsSyncMode.String(),
+			HostsSyncMode:        HostsSyncMode(DefaultHostsSyncMode),
 		},
 	}
 
@@ -133,16 +141,12 @@ d0566ce7e71d880487a89385296ab8a454967e975955ce0e59bff7991d5539d6`
 // DefaultSwarmPort is the default swarm discovery port
 const DefaultSwarmPort = 4001
 
+// DefaultEnableAutoRelay is the default value for RelayClient.Enabled
 const DefaultEnableAutoRelay = true
 
 func addressesConfig() Addresses {
 	return Addresses{
-		Swarm: []string{
-			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", DefaultSwarmPort),
-			fmt.Sprintf("/ip6/::/tcp/%d", DefaultSwarmPort),
-			fmt.Sprintf("/ip4/0.0.0.0/udp/%d/quic", DefaultSwarmPort),
-			fmt.Sprintf("/ip6/::/udp/%d/quic", DefaultSwarmPort),
-		},
+		Swarm:      swarmAddresses(DefaultSwarmPort, Transports{}),
 		Announce:   []string{},
 		NoAnnounce: []string{},
 		API:        Strings{"/ip4/127.0.0.1/tcp/5001"},
@@ -151,6 +155,47 @@ func addressesConfig() Addresses {
 	}
 }
 
+// swarmAddresses returns the IPv4 and IPv6 swarm listen addresses on the
+// given port for every transport enabled in t that the linked go-multiaddr
+// can parse.
+func swarmAddresses(port int, t Transports) []string {
+	return swarmAddressesFor(port, t, multiaddrSupported)
+}
+
+// multiaddrSupported reports whether addr parses with the go-multiaddr the
+// binary is linked against, which the daemon uses too.
+func multiaddrSupported(addr string) bool {
+	_, err := ma.NewMultiaddr(addr)
+	return err == nil
+}
+
+func swarmAddressesFor(port int, t Transports, supported func(string) bool) []string {
+	var suffixes []string
+	if t.Network.TCP.WithDefault(true) {
+		suffixes = append(suffixes, fmt.Sprintf("/tcp/%d", port))
+	}
+	if t.Network.QUIC.WithDefault(true) {
+		suffixes = append(suffixes, fmt.Sprintf("/udp/%d/quic", port))
+	}
+	if t.Network.QUICv1.WithDefault(false) {
+		suffixes = append(suffixes, fmt.Sprintf("/udp/%d/quic-v1", port))
+	}
+	if t.Network.WebTransport.WithDefault(false) {
+		suffixes = append(suffixes, fmt.Sprintf("/udp/%d/quic-v1/webtransport", port))
+	}
+	if t.Network.WebRTCDirect.WithDefault(false) {
+		suffixes = append(suffixes, fmt.Sprintf("/udp/%d/webrtc-direct", port))
+	}
+
+	addrs := make([]string, 0, 2*len(suffixes))
+	for _, suffix := range suffixes {
+		if supported("/ip4/0.0.0.0" + suffix) {
+			addrs = append(addrs, "/ip4/0.0.0.0"+suffix, "/ip6/::"+suffix)
+		}
+	}
+	return addrs
+}
+
 // DefaultDatastoreConfig is an internal function exported to aid in testing.
 func DefaultDatastoreConfig() Datastore {
 	return Datastore{
@@ -163,92 +208,226 @@ func DefaultDatastoreConfig() Datastore {
 }
 
 func badgerSpec() map[string]interface{} {
-	return map[string]interface{}{
-		"type":   "measure",
-		"prefix": "badger.datastore",
-		"child": map[string]interface{}{
-			"type":       "badgerds",
-			"path":       "badgerds",
-			"syncWrites": false,
-			"truncate":   true,
+	return DefaultBadgerSpec().SpecMap()
+}
+
+func flatfsSpec() map[string]interface{} {
+	return DefaultFlatfsSpec().SpecMap()
+}
+
+// DefaultBadgerSpec returns the typed spec used by the badgerds profile.
+func DefaultBadgerSpec() DatastoreSpec {
+	return &MeasureSpec{
+		Prefix: "badger.datastore",
+		Child: &BadgerdsSpec{
+			Path:       "badgerds",
+			SyncWrites: false,
+			Truncate:   true,
 		},
 	}
 }
 
-func flatfsSpec() map[string]interface{} {
-	return map[string]interface{}{
-		"type": "mount",
-		"mounts": []interface{}{
-			map[string]interface{}{
-				"mountpoint": "/blocks",
-				"type":       "measure",
-				"prefix":     "flatfs.datastore",
-				"child": map[string]interface{}{
-					"type":      "flatfs",
-					"path":      "blocks",
-					"sync":      true,
-					"shardFunc": "/repo/flatfs/shard/v1/next-to-last/2",
+// DefaultFlatfsSpec returns the typed spec of the default datastore: blocks in
+// flatfs, everything else in leveldb.
+func DefaultFlatfsSpec() DatastoreSpec {
+	return &MountSpec{
+		Mounts: []MountPoint{
+			{
+				Mountpoint: "/blocks",
+				Child: &MeasureSpec{
+					Prefix: "flatfs.datastore",
+					Child: &FlatfsSpec{
+						Path:      "blocks",
+						Sync:      true,
+						ShardFunc: "/repo/flatfs/shard/v1/next-to-last/2",
+					},
 				},
 			},
-			map[string]interface{}{
-				"mountpoint": "/",
-				"type":       "measure",
-				"prefix":     "leveldb.datastore",
-				"child": map[string]interface{}{
-					"type":        "levelds",
-					"path":        "datastore",
-					"compression": "none",
+			leveldbRootMount(),
+		},
+	}
+}
+
+// leveldbRootMount mounts the leveldb datastore of the default spec at "/".
+func leveldbRootMount() MountPoint {
+	return MountPoint{
+		Mountpoint: "/",
+		Child: &MeasureSpec{
+			Prefix: "leveldb.datastore",
+			Child: &LeveldsSpec{
+				Path:        "datastore",
+				Compression: "none",
+			},
+		},
+	}
+}
+
+// DefaultPebbleSpec returns the typed spec used by the pebbleds profile.
+func DefaultPebbleSpec() DatastoreSpec {
+	return &MeasureSpec{
+		Prefix: "pebble.datastore",
+		Child:  &PebbledsSpec{Path: "pebbleds"},
+	}
+}
+
+// DefaultS3Spec returns the typed spec used by the s3ds profile: blocks in an
+// S3-compatible bucket, everything else in leveldb. The endpoint may be empty
+// for AWS.
+func DefaultS3Spec(bucket, region, endpoint string) DatastoreSpec {
+	return &MountSpec{
+		Mounts: []MountPoint{
+			{
+				Mountpoint: "/blocks",
+				Child: &MeasureSpec{
+					Prefix: "s3.datastore",
+					Child: &S3dsSpec{
+						Bucket:         bucket,
+						Region:         region,
+						RegionEndpoint: endpoint,
+						RootDirectory:  "blocks",
+					},
 				},
 			},
+			leveldbRootMount(),
 		},
 	}
 }
 
+// DefaultStorageHostConfig returns the default StorageHost settings. A zero
+// price leaves pricing to the network default.
+func DefaultStorageHostConfig() StorageHost {
+	return StorageHost{
+		MinContractDuration: Duration(30 * 24 * time.Hour),
+		MaxContractDuration: Duration(2 * 365 * 24 * time.Hour),
+		MaxShardSize:        "1GiB",
+		ContractManager:     DefaultContractManager(),
+	}
+}
+
+// DefaultStorageClientConfig returns the default StorageClient settings:
+// 30 day contracts with 10 data and 20 parity shards on the best scored
+// hosts, without budget or price limits.
+func DefaultStorageClientConfig() StorageClient {
+	return StorageClient{
+		DefaultStorageLength: Duration(30 * 24 * time.Hour),
+		DataShards:           10,
+		ParityShards:         20,
+		HostSelection:        HostSelectionScore,
+	}
+}
+
+// DefaultContractManager returns the default contract manager limits.
+func DefaultContractManager() *ContractManager {
+	return &ContractManager{
+		LowWater:  100,
+		HighWater: 300,
+		Threshold: 10 * 1000 * 1000,
+	}
+}
+
+// DefaultRepairConfig returns the mainnet repair settings.
+func DefaultRepairConfig() StorageWorker {
+	return StorageWorker{
+		Concurrency:  4,
+		JobTimeout:   Duration(30 * time.Minute),
+		MaxBandwidth: "20MB",
+		MinReward:    1000,
+		Interval:     Duration(10 * time.Minute),
+	}
+}
+
+// DefaultRepairConfigTestnet returns the testnet repair settings.
+func DefaultRepairConfigTestnet() StorageWorker {
+	return StorageWorker{
+		Concurrency: 2,
+		JobTimeout:  Duration(30 * time.Minute),
+		Interval:    Duration(5 * time.Minute),
+	}
+}
+
+// DefaultRepairConfigDev returns the dev repair settings.
+func DefaultRepairConfigDev() StorageWorker {
+	return StorageWorker{
+		Concurrency: 1,
+		JobTimeout:  Duration(10 * time.Minute),
+		Interval:    Duration(time.Minute),
+	}
+}
+
+// DefaultChallengeConfig returns the mainnet challenge settings.
+func DefaultChallengeConfig() StorageWorker {
+	return StorageWorker{
+		Concurrency:  8,
+		JobTimeout:   Duration(5 * time.Minute),
+		MaxBandwidth: "5MB",
+		MinReward:    100,
+		Interval:     Duration(10 * time.Minute),
+	}
+}
+
+// DefaultChallengeConfigTestnet returns the testnet challenge settings.
+func DefaultChallengeConfigTestnet() StorageWorker {
+	return StorageWorker{
+		Concurrency: 4,
+		JobTimeout:  Duration(5 * time.Minute),
+		Interval:    Duration(5 * time.Minute),
+	}
+}
+
+// DefaultChallengeConfigDev returns the dev challenge settings.
+func DefaultChallengeConfigDev() StorageWorker {
+	return StorageWorker{
+		Concurrency: 1,
+		JobTimeout:  Duration(5 * time.Minute),
+		Interval:    Duration(time.Minute),
+	}
+}
+
 // DefaultServicesConfig returns the default set of configs for external services.
 func DefaultServicesConfig() Services {
 	return Services{
-		StatusServerDomain: "https://status.btfs.io",
-		HubDomain:          "https://hub.btfs.io",
-		EscrowDomain:       "https://escrow.btfs.io",
-		GuardDomain:        "https://guard.btfs.io",
-		ExchangeDomain:     "https://exchange.bt.co",
-		SolidityDomain:     "grpc.trongrid.io:50052",
-		FullnodeDomain:     "grpc.trongrid.io:50051",
-		TrongridDomain:     "https://api.trongrid.io",
-		EscrowPubKeys:      []string{"CAISIQPAfB2Mt2ic+n3JcL4vrKXxBCmB0iNh+5BYiXdJNWed/Q=="},
-		GuardPubKeys:       []string{"CAISIQJ16EiwvGko4SaBEEUFyMdNZp1vKsTLgIXCY6fRa3/Obg=="},
+		StatusServerDomain: ServiceEndpoint{URL: "https://status.btfs.io"},
+		HubDomain:          ServiceEndpoint{URL: "https://hub.btfs.io"},
+		EscrowDomain:       ServiceEndpoint{URL: "https://escrow.btfs.io"},
+		GuardDomain:        ServiceEndpoint{URL: "https://guard.btfs.io"},
+		ExchangeDomain:     ServiceEndpoint{URL: "https://exchange.bt.co"},
+		SolidityDomain:     ServiceEndpoint{URL: "grpc.trongrid.io:50052"},
+		FullnodeDomain:     ServiceEndpoint{URL: "grpc.trongrid.io:50051"},
+		TrongridDomain:     ServiceEndpoint{URL: "https://api.trongrid.io"},
+		EscrowPubKeys:      ServiceKeys{{Key: "CAISIQPAfB2Mt2ic+n3JcL4vrKXxBCmB0iNh+5BYiXdJNWed/Q==", Status: ServiceKeyActive}},
+		GuardPubKeys:       ServiceKeys{{Key: "CAISIQJ16EiwvGko4SaBEEUFyMdNZp1vKsTLgIXCY6fRa3/Obg==", Status: ServiceKeyActive}},
 	}
 }
 
 // DefaultServicesConfigDev returns the default set of configs for dev external services.
 func DefaultServicesConfigDev() Services {
 	return Services{
-		StatusServerDomain: "https://status-dev.btfs.io",
-		HubDomain:          "https://hub-dev.btfs.io",
-		EscrowDomain:       "https://escrow-dev.btfs.io",
-		GuardDomain:        "https://guard-dev.btfs.io",
-		ExchangeDomain:     "https://exchange-dev.bt.co",
-		SolidityDomain:     "grpc.trongrid.io:50052",
-		FullnodeDomain:     "grpc.trongrid.io:50051",
-		TrongridDomain:     "https://api.shasta.trongrid.io",
-		EscrowPubKeys:      []string{"CAISIQJOcRK0q4TOwpswAkvMMq33ksQfhplEyhHcZnEUFbthQg=="},
-		GuardPubKeys:       []string{"CAISIQJhPBQWKPPjYcuPWR9sl+QlN0wJSRbQs3yUKmggvubXwg=="},
+		StatusServerDomain: ServiceEndpoint{URL: "https://status-dev.btfs.io"},
+		HubDomain:          ServiceEndpoint{URL: "https://hub-dev.btfs.io"},
+		EscrowDomain:       ServiceEndpoint{URL: "https://escrow-dev.btfs.io"},
+		GuardDomain:        ServiceEndpoint{URL: "https://guard-dev.btfs.io"},
+		ExchangeDomain:     ServiceEndpoint{URL: "https://exchange-dev.bt.co"},
+		SolidityDomain:     ServiceEndpoint{URL: "grpc.trongrid.io:50052"},
+		FullnodeDomain:     ServiceEndpoint{URL: "grpc.trongrid.io:50051"},
+		TrongridDomain:     ServiceEndpoint{URL: "https://api.shasta.trongrid.io"},
+		EscrowPubKeys:      ServiceKeys{{Key: "CAISIQJOcRK0q4TOwpswAkvMMq33ksQfhplEyhHcZnEUFbthQg==", Status: ServiceKeyActive}},
+		GuardPubKeys:       ServiceKeys{{Key: "CAISIQJhPBQWKPPjYcuPWR9sl+QlN0wJSRbQs3yUKmggvubXwg==", Status: ServiceKeyActive}},
 	}
 }
 
 // DefaultServicesConfigTestnet returns the default set of configs for testnet external services.
 func DefaultServicesConfigTestnet() Services {
 	return Services{
-		StatusServerDomain: "https://status-staging.btfs.io",
-		HubDomain:          "https://hub-staging.btfs.io",
-		EscrowDomain:       "https://escrow-staging.btfs.io",
-		GuardDomain:        "https://guard-staging.btfs.io",
-		ExchangeDomain:     "https://exchange-staging.bt.co",
-		SolidityDomain:     "grpc.trongrid.io:50052",
-		FullnodeDomain:     "grpc.trongrid.io:50051",
-		TrongridDomain:     "https://api.shasta.trongrid.io",
-		EscrowPubKeys:      []string{"CAISIQJOcRK0q4TOwpswAkvMMq33ksQfhplEyhHcZnEUFbthQg=="},
-		GuardPubKeys:       []string{"CAISIQJhPBQWKPPjYcuPWR9sl+QlN0wJSRbQs3yUKmggvubXwg=="},
+		StatusServerDomain: ServiceEndpoint{URL: "https://status-staging.btfs.io"},
+		HubDomain:          ServiceEndpoint{URL: "https://hub-staging.btfs.io"},
+		EscrowDomain:       ServiceEndpoint{URL: "https://escrow-staging.btfs.io"},
+		GuardDomain:        ServiceEndpoint{URL: "https://guard-staging.btfs.io"},
+		ExchangeDomain:     ServiceEndpoint{URL: "https://exchange-staging.bt.co"},
+		SolidityDomain:     ServiceEndpoint{URL: "grpc.trongrid.io:50052"},
+		FullnodeDomain:     ServiceEndpoint{URL: "grpc.trongrid.io:50051"},
+		TrongridDomain:     ServiceEndpoint{URL: "https://api.shasta.trongrid.io"},
+		EscrowPubKeys:      ServiceKeys{{Key: "CAISIQJOcRK0q4TOwpswAkvMMq33ksQfhplEyhHcZnEUFbthQg==", Status: ServiceKeyActive}},
+		GuardPubKeys:       ServiceKeys{{Key: "CAISIQJhPBQWKPPjYcuPWR9sl+QlN0wJSRbQs3yUKmggvubXwg==", Status: ServiceKeyActive}},
 	}
 }
 
@@ -8,20 +8,38 @@ import (
 )
 
 func migrate_1_Services(cfg *Config) bool {
-	emptyServices := Services{}
-	if reflect.DeepEqual(cfg.Services, emptyServices) {
+	if cfg.Services.unset() {
 		cfg.Services = DefaultServicesConfig()
 		return true
 	}
-	if len(cfg.Services.EscrowPubKeys) == 0 || len(cfg.Services.GuardPubKeys) == 0 {
-		cfg.Services = DefaultServicesConfig()
-		return true
+	ds := defaultServicesFor(cfg)
+	updated := false
+	if len(cfg.Services.EscrowPubKeys) == 0 {
+		cfg.Services.EscrowPubKeys = ds.EscrowPubKeys
+		updated = true
+	}
+	if len(cfg.Services.GuardPubKeys) == 0 {
+		cfg.Services.GuardPubKeys = ds.GuardPubKeys
+		updated = true
+	}
+	return updated
+}
+
+// defaultServicesFor returns the default services of the network a config
+// belongs to, told apart by its escrow domain.
+func defaultServicesFor(cfg *Config) Services {
+	switch {
+	case strings.Contains(cfg.Services.EscrowDomain.URL, "dev"):
+		return DefaultServicesConfigDev()
+	case strings.Contains(cfg.Services.EscrowDomain.URL, "staging"):
+		return DefaultServicesConfigTestnet()
+	default:
+		return DefaultServicesConfig()
 	}
-	return false
 }
 
 func migrate_2_StatusUrl(cfg *Config) bool {
-	if strings.Contains(cfg.Services.StatusServerDomain, "db.btfs.io") {
+	if strings.Contains(cfg.Services.StatusServerDomain.URL, "db.btfs.io") {
 		ds := DefaultServicesConfig()
 		cfg.Services.StatusServerDomain = ds.StatusServerDomain
 		return true
@@ -69,6 +87,10 @@ func migrate_5_Bootstrap_node(cfg *Config) bool {
 }
 
 func migrate_6_EnableAutoRelay(cfg *Config) bool {
+	// superseded by RelayClient.Enabled, see migrate_18_RelayV2
+	if cfg.Swarm.RelayClient.Enabled != Default {
+		return false
+	}
 	if cfg.Swarm.EnableAutoRelay != DefaultEnableAutoRelay {
 		cfg.Swarm.EnableAutoRelay = DefaultEnableAutoRelay
 		return true
@@ -118,15 +140,15 @@ func migrate_8_AnnounceDefault(cfg *Config, beforeV1B2 bool) bool {
 }
 
 func migrate_9_WalletDomain(cfg *Config) bool {
-	if strings.Contains(cfg.Services.EscrowDomain, "dev") || strings.Contains(cfg.Services.EscrowDomain, "staging") {
-		if len(cfg.Services.ExchangeDomain) == 0 {
+	if strings.Contains(cfg.Services.EscrowDomain.URL, "dev") || strings.Contains(cfg.Services.EscrowDomain.URL, "staging") {
+		if len(cfg.Services.ExchangeDomain.URL) == 0 {
 			ds := DefaultServicesConfigTestnet()
 			cfg.Services.ExchangeDomain = ds.ExchangeDomain
 			cfg.Services.SolidityDomain = ds.SolidityDomain
 			return true
 		}
 	} else {
-		if len(cfg.Services.ExchangeDomain) == 0 {
+		if len(cfg.Services.ExchangeDomain.URL) == 0 {
 			ds := DefaultServicesConfig()
 			cfg.Services.ExchangeDomain = ds.ExchangeDomain
 			cfg.Services.SolidityDomain = ds.SolidityDomain
@@ -165,8 +187,8 @@ func migrate_10_CleanAPIHTTPHeaders(cfg *Config) bool {
 
 func migrate_11_ExchangeDomain(cfg *Config) bool {
 	// migrate staging domain -> staging
-	if strings.Contains(cfg.Services.EscrowDomain, "staging") &&
-		strings.Contains(cfg.Services.ExchangeDomain, "dev") {
+	if strings.Contains(cfg.Services.EscrowDomain.URL, "staging") &&
+		strings.Contains(cfg.Services.ExchangeDomain.URL, "dev") {
 		ds := DefaultServicesConfigTestnet()
 		cfg.Services.ExchangeDomain = ds.ExchangeDomain
 		return true
@@ -175,14 +197,14 @@ func migrate_11_ExchangeDomain(cfg *Config) bool {
 }
 
 func migrate_12_FullnodeDomain(cfg *Config) bool {
-	if strings.Contains(cfg.Services.EscrowDomain, "dev") || strings.Contains(cfg.Services.EscrowDomain, "staging") {
-		if len(cfg.Services.FullnodeDomain) == 0 {
+	if strings.Contains(cfg.Services.EscrowDomain.URL, "dev") || strings.Contains(cfg.Services.EscrowDomain.URL, "staging") {
+		if len(cfg.Services.FullnodeDomain.URL) == 0 {
 			ds := DefaultServicesConfigTestnet()
 			cfg.Services.FullnodeDomain = ds.FullnodeDomain
 			return true
 		}
 	} else {
-		if len(cfg.Services.FullnodeDomain) == 0 {
+		if len(cfg.Services.FullnodeDomain.URL) == 0 {
 			ds := DefaultServicesConfig()
 			cfg.Services.FullnodeDomain = ds.FullnodeDomain
 			return true
@@ -192,12 +214,8 @@ func migrate_12_FullnodeDomain(cfg *Config) bool {
 }
 
 func migrate_13_HostContractManager(cfg *Config) bool {
-	if cfg.UI.Host.ContractManager == nil {
-		cfg.UI.Host.ContractManager = &ContractManager{
-			LowWater:  100,
-			HighWater: 300,
-			Threshold: 10 * 1000 * 1000,
-		}
+	if cfg.UI.Host.ContractManager == nil && cfg.StorageHost.ContractManager == nil {
+		cfg.StorageHost.ContractManager = DefaultContractManager()
 		return true
 	}
 	return false
@@ -226,14 +244,14 @@ func migrate_15_MissingRemoteAPI(cfg *Config) bool {
 }
 
 func migrate_16_TrongridDomain(cfg *Config) bool {
-	if strings.Contains(cfg.Services.EscrowDomain, "dev") || strings.Contains(cfg.Services.EscrowDomain, "staging") {
-		if len(cfg.Services.TrongridDomain) == 0 {
+	if strings.Contains(cfg.Services.EscrowDomain.URL, "dev") || strings.Contains(cfg.Services.EscrowDomain.URL, "staging") {
+		if len(cfg.Services.TrongridDomain.URL) == 0 {
 			ds := DefaultServicesConfigTestnet()
 			cfg.Services.TrongridDomain = ds.TrongridDomain
 			return true
 		}
 	} else {
-		if len(cfg.Services.TrongridDomain) == 0 {
+		if len(cfg.Services.TrongridDomain.URL) == 0 {
 			ds := DefaultServicesConfig()
 			cfg.Services.TrongridDomain = ds.TrongridDomain
 			return true
@@ -242,6 +260,243 @@ func migrate_16_TrongridDomain(cfg *Config) bool {
 	return false
 }
 
+// rewrites draft-29 QUIC (`/quic`) addresses to QUIC v1 (`/quic-v1`) once the
+// user has opted in by setting Swarm.Transports.Network.QUICv1 to true. The
+// addresses are kept until the linked go-multiaddr can parse `/quic-v1`.
+func migrate_17_QUICv1(cfg *Config) bool {
+	return migrateQUICv1(cfg, multiaddrSupported)
+}
+
+func migrateQUICv1(cfg *Config, supported func(string) bool) bool {
+	if cfg.Swarm.Transports.Network.QUICv1 != True {
+		return false
+	}
+	updated := false
+	for _, addrs := range []*[]string{
+		&cfg.Addresses.Swarm,
+		&cfg.Addresses.Announce,
+		&cfg.Addresses.NoAnnounce,
+	} {
+		if out, ok := rewriteQUICDraftAddrs(*addrs, supported); ok {
+			*addrs = out
+			updated = true
+		}
+	}
+	return updated
+}
+
+func rewriteQUICDraftAddrs(addrs []string, supported func(string) bool) ([]string, bool) {
+	changed := false
+	out := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		parts := strings.Split(addr, "/")
+		rewritten := false
+		for i, part := range parts {
+			if part == "quic" {
+				parts[i] = "quic-v1"
+				rewritten = true
+			}
+		}
+		if rewritten && supported(strings.Join(parts, "/")) {
+			addr = strings.Join(parts, "/")
+			changed = true
+		}
+		out = append(out, addr)
+	}
+	if !changed {
+		return addrs, false
+	}
+	// the rewritten address may already be present next to the draft one
+	return appendSingle(nil, out), true
+}
+
+// moves the deprecated relay flags onto the RelayClient and RelayService
+// sections. Explicit settings in the new sections take precedence.
+func migrate_18_RelayV2(cfg *Config) bool {
+	updated := false
+	if cfg.Swarm.EnableRelayHop {
+		if cfg.Swarm.RelayService.Enabled == Default {
+			cfg.Swarm.RelayService.Enabled = True
+		}
+		cfg.Swarm.EnableRelayHop = false
+		updated = true
+	}
+	if cfg.Swarm.EnableAutoRelay {
+		if cfg.Swarm.RelayClient.Enabled == Default {
+			cfg.Swarm.RelayClient.Enabled = True
+		}
+		cfg.Swarm.EnableAutoRelay = false
+		updated = true
+	}
+	if cfg.Swarm.DisableRelay {
+		if cfg.Swarm.Transports.Network.Relay == Default {
+			cfg.Swarm.Transports.Network.Relay = False
+		}
+		cfg.Swarm.DisableRelay = false
+		updated = true
+	}
+	return updated
+}
+
+// moves the Access-Control-* entries of API.HTTPHeaders and
+// Gateway.HTTPHeaders into the typed CORS sections, so that CORS is their
+// only source. Entries that cannot be converted are left in HTTPHeaders.
+func migrate_19_TypedCORS(cfg *Config) bool {
+	a := migrateCORSHeaders(&cfg.API.CORS, cfg.API.HTTPHeaders)
+	g := migrateCORSHeaders(&cfg.Gateway.CORS, cfg.Gateway.HTTPHeaders)
+	return a || g
+}
+
+func migrateCORSHeaders(cors **CORS, headers map[string][]string) bool {
+	if *cors != nil {
+		return false
+	}
+	if *cors = corsFromHTTPHeaders(headers); *cors == nil {
+		return false
+	}
+	for k := range headers {
+		if isCORSHeader(k) {
+			delete(headers, k)
+		}
+	}
+	return true
+}
+
+// moves UI.Host.ContractManager to StorageHost.ContractManager and fills in
+// the zero StorageHost settings of storage hosts, or of a section already in
+// use, which Validate would otherwise reject.
+func migrate_20_StorageHost(cfg *Config) bool {
+	updated := false
+	if cfg.UI.Host.ContractManager != nil {
+		if cfg.StorageHost.ContractManager == nil {
+			cfg.StorageHost.ContractManager = cfg.UI.Host.ContractManager
+		}
+		cfg.UI.Host.ContractManager = nil
+		updated = true
+	}
+	if !cfg.Experimental.StorageHostEnabled && unset(&cfg.StorageHost) {
+		return updated
+	}
+	h, defaults := &cfg.StorageHost, DefaultStorageHostConfig()
+	if h.MinContractDuration == 0 {
+		h.MinContractDuration = defaults.MinContractDuration
+		updated = true
+	}
+	if h.MaxContractDuration == 0 {
+		h.MaxContractDuration = defaults.MaxContractDuration
+		updated = true
+	}
+	if h.MaxShardSize == "" {
+		h.MaxShardSize = defaults.MaxShardSize
+		updated = true
+	}
+	if h.ContractManager == nil {
+		h.ContractManager = defaults.ContractManager
+		updated = true
+	}
+	return updated
+}
+
+// fills in the StorageClient defaults of storage clients, and the zero
+// required settings of a section already in use, which Validate would
+// otherwise reject.
+func migrate_21_StorageClient(cfg *Config) bool {
+	s, defaults := &cfg.StorageClient, DefaultStorageClientConfig()
+	if unset(s) {
+		if !cfg.Experimental.StorageClientEnabled {
+			return false
+		}
+		*s = defaults
+		return true
+	}
+	updated := false
+	if s.DefaultStorageLength == 0 {
+		s.DefaultStorageLength = defaults.DefaultStorageLength
+		updated = true
+	}
+	if s.DataShards == 0 {
+		s.DataShards = defaults.DataShards
+		updated = true
+	}
+	if s.HostSelection == "" {
+		s.HostSelection = defaults.HostSelection
+		updated = true
+	}
+	return updated
+}
+
+// normalises Experiments.HostsSyncMode. Decoding the typed mode already
+// canonicalises the case and maps an empty mode to SCORE; this resets unknown
+// modes to the default of the network and drops HostsSync overrides of
+// unknown modes.
+func migrate_22_HostsSyncMode(cfg *Config) bool {
+	updated := false
+	for mode := range cfg.HostsSync.Modes {
+		if !mode.Valid() {
+			delete(cfg.HostsSync.Modes, mode)
+			updated = true
+		}
+	}
+	mode := cfg.Experimental.HostsSyncMode
+	if !mode.Valid() {
+		if cfg.Swarm.SwarmKey == DefaultTestnetSwarmKey {
+			cfg.Experimental.HostsSyncMode = HostsSyncMode(DefaultHostsSyncModeDev)
+		} else {
+			cfg.Experimental.HostsSyncMode = HostsSyncMode(DefaultHostsSyncMode)
+		}
+		return true
+	}
+	return updated
+}
+
+// fills in the Repair and Challenge defaults of the config's network when the
+// matching feature is enabled, and the zero required settings of a section
+// already in use, which Validate would otherwise reject.
+func migrate_23_StorageWorkers(cfg *Config) bool {
+	repair, challenge := workerDefaults(cfg)
+	r := migrateStorageWorker(&cfg.Repair, repair, cfg.Experimental.HostRepairEnabled)
+	c := migrateStorageWorker(&cfg.Challenge, challenge, cfg.Experimental.HostChallengeEnabled)
+	return r || c
+}
+
+func migrateStorageWorker(w *StorageWorker, defaults StorageWorker, enabled bool) bool {
+	if unset(w) {
+		if !enabled {
+			return false
+		}
+		*w = defaults
+		return true
+	}
+	updated := false
+	if w.Concurrency == 0 {
+		w.Concurrency = defaults.Concurrency
+		updated = true
+	}
+	if w.JobTimeout == 0 {
+		w.JobTimeout = defaults.JobTimeout
+		updated = true
+	}
+	if w.Interval == 0 {
+		w.Interval = defaults.Interval
+		updated = true
+	}
+	return updated
+}
+
+// workerDefaults returns the repair and challenge defaults of the network a
+// config belongs to, told apart by its escrow domain like the services
+// migrations do.
+func workerDefaults(cfg *Config) (repair, challenge StorageWorker) {
+	switch {
+	case strings.Contains(cfg.Services.EscrowDomain.URL, "dev"):
+		return DefaultRepairConfigDev(), DefaultChallengeConfigDev()
+	case strings.Contains(cfg.Services.EscrowDomain.URL, "staging"):
+		return DefaultRepairConfigTestnet(), DefaultChallengeConfigTestnet()
+	default:
+		return DefaultRepairConfig(), DefaultChallengeConfig()
+	}
+}
+
 // MigrateConfig migrates config options to the latest known version
 // It may correct incompatible configs as well
 // inited = just initialized in the same call
@@ -266,5 +521,12 @@ func MigrateConfig(cfg *Config, inited, hasHval bool) bool {
 	updated = migrate_14_TestnetBootstrapNodes(cfg) || updated
 	updated = migrate_15_MissingRemoteAPI(cfg) || updated
 	updated = migrate_16_TrongridDomain(cfg) || updated
+	updated = migrate_17_QUICv1(cfg) || updated
+	updated = migrate_18_RelayV2(cfg) || updated
+	updated = migrate_19_TypedCORS(cfg) || updated
+	updated = migrate_20_StorageHost(cfg) || updated
+	updated = migrate_21_StorageClient(cfg) || updated
+	updated = migrate_22_HostsSyncMode(cfg) || updated
+	updated = migrate_23_StorageWorkers(cfg) || updated
 	return updated
 }
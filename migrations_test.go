@@ -0,0 +1,308 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	ma "github.com/multiformats/go-multiaddr"
+	hubpb "github.com/tron-us/go-btfs-common/protos/hub"
+)
+
+func TestMigrateQUICv1(t *testing.T) {
+	cfg := new(Config)
+	cfg.Addresses.Swarm = []string{
+		"/ip4/0.0.0.0/tcp/4001",
+		"/ip4/0.0.0.0/udp/4001/quic",
+		"/ip4/0.0.0.0/udp/4001/quic-v1",
+		"/ip6/::/udp/4001/quic",
+	}
+	cfg.Addresses.Announce = []string{"/ip4/1.2.3.4/udp/4001/quic/p2p/QmSomePeer"}
+
+	if migrate_17_QUICv1(cfg) {
+		t.Fatal("expected no migration without opting in")
+	}
+
+	cfg.Swarm.Transports.Network.QUICv1 = True
+	if !multiaddrSupported("/ip4/0.0.0.0/udp/4001/quic-v1") && migrate_17_QUICv1(cfg) {
+		t.Fatal("expected no migration while /quic-v1 cannot be parsed")
+	}
+	all := func(string) bool { return true }
+	if !migrateQUICv1(cfg, all) {
+		t.Fatal("expected migration after opting in")
+	}
+	expected := []string{
+		"/ip4/0.0.0.0/tcp/4001",
+		"/ip4/0.0.0.0/udp/4001/quic-v1",
+		"/ip6/::/udp/4001/quic-v1",
+	}
+	if !reflect.DeepEqual(cfg.Addresses.Swarm, expected) {
+		t.Fatalf("expected %v, got %v", expected, cfg.Addresses.Swarm)
+	}
+	if cfg.Addresses.Announce[0] != "/ip4/1.2.3.4/udp/4001/quic-v1/p2p/QmSomePeer" {
+		t.Fatalf("announce address not rewritten: %s", cfg.Addresses.Announce[0])
+	}
+
+	if migrateQUICv1(cfg, all) {
+		t.Fatal("expected migration to be idempotent")
+	}
+}
+
+func TestSwarmAddresses(t *testing.T) {
+	var tr Transports
+	tr.Network.QUIC = False
+	tr.Network.QUICv1 = True
+	tr.Network.WebRTCDirect = True
+
+	expected := []string{
+		"/ip4/0.0.0.0/tcp/4001",
+		"/ip6/::/tcp/4001",
+		"/ip4/0.0.0.0/udp/4001/quic-v1",
+		"/ip6/::/udp/4001/quic-v1",
+		"/ip4/0.0.0.0/udp/4001/webrtc-direct",
+		"/ip6/::/udp/4001/webrtc-direct",
+	}
+	addrs := swarmAddressesFor(4001, tr, func(string) bool { return true })
+	if !reflect.DeepEqual(addrs, expected) {
+		t.Fatalf("expected %v, got %v", expected, addrs)
+	}
+
+	// the emitted addresses must parse with the go-multiaddr we build
+	// against, whatever is enabled
+	tr.Network.WebTransport = True
+	for _, addr := range append(swarmAddresses(4001, Transports{}), swarmAddresses(4001, tr)...) {
+		if _, err := ma.NewMultiaddr(addr); err != nil {
+			t.Errorf("%s: %s", addr, err)
+		}
+	}
+}
+
+func TestMigrateRelayV2(t *testing.T) {
+	cfg := new(Config)
+	cfg.Swarm.EnableRelayHop = true
+	cfg.Swarm.EnableAutoRelay = true
+	cfg.Swarm.RelayClient.Enabled = False
+
+	if !migrate_18_RelayV2(cfg) {
+		t.Fatal("expected deprecated relay flags to be migrated")
+	}
+	if cfg.Swarm.RelayService.Enabled != True {
+		t.Fatalf("expected relay service to be enabled, got %s", cfg.Swarm.RelayService.Enabled)
+	}
+	if cfg.Swarm.RelayClient.Enabled != False {
+		t.Fatal("explicit RelayClient setting was overridden")
+	}
+	if cfg.Swarm.EnableRelayHop || cfg.Swarm.EnableAutoRelay {
+		t.Fatal("expected deprecated flags to be cleared")
+	}
+
+	// migrate_6 must not resurrect the deprecated flag afterwards
+	if migrate_6_EnableAutoRelay(cfg) || migrate_18_RelayV2(cfg) {
+		t.Fatal("expected relay migrations to be idempotent")
+	}
+}
+
+func TestMigrateTypedCORS(t *testing.T) {
+	cfg := new(Config)
+	cfg.API.HTTPHeaders = map[string][]string{
+		"Access-Control-Allow-Origin":      {"*"},
+		"Access-Control-Allow-Methods":     {"PUT, GET", "POST"},
+		"Access-Control-Allow-Credentials": {"true"},
+		"X-Custom":                         {"1"},
+	}
+	cfg.Gateway.HTTPHeaders = map[string][]string{
+		"Access-Control-Max-Age": {"soon"},
+	}
+
+	if !migrate_19_TypedCORS(cfg) {
+		t.Fatal("expected CORS headers to be migrated")
+	}
+	expected := &CORS{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"PUT", "GET", "POST"},
+		AllowCredentials: true,
+	}
+	if !reflect.DeepEqual(cfg.API.CORS, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, cfg.API.CORS)
+	}
+	if len(cfg.API.HTTPHeaders) != 1 || cfg.API.HTTPHeaders["X-Custom"] == nil {
+		t.Fatalf("expected the CORS entries to be moved out of HTTPHeaders, got %v", cfg.API.HTTPHeaders)
+	}
+	if cfg.Gateway.CORS != nil || len(cfg.Gateway.HTTPHeaders) != 1 {
+		t.Fatal("expected unconvertible gateway headers to be left alone")
+	}
+	if len(cfg.Warnings()) == 0 {
+		t.Fatal("expected a warning for the migrated credentials setting")
+	}
+	if migrate_19_TypedCORS(cfg) {
+		t.Fatal("expected migration to be idempotent")
+	}
+}
+
+func TestMigrateStorageHost(t *testing.T) {
+	cfg := new(Config)
+	cfg.UI.Host.ContractManager = &ContractManager{LowWater: 10, HighWater: 20, Threshold: 5}
+	cfg.StorageHost.PricePerGiBMonth = 1250
+
+	migrate_13_HostContractManager(cfg)
+	if !migrate_20_StorageHost(cfg) {
+		t.Fatal("expected the contract manager to be moved")
+	}
+	if cfg.UI.Host.ContractManager != nil {
+		t.Fatal("expected UI.Host.ContractManager to be cleared")
+	}
+	if *cfg.StorageHost.ContractManager != (ContractManager{LowWater: 10, HighWater: 20, Threshold: 5}) {
+		t.Fatalf("contract manager values not preserved: %+v", cfg.StorageHost.ContractManager)
+	}
+	if cfg.StorageHost.PricePerGiBMonth != 1250 || cfg.StorageHost.MaxShardSize != DefaultStorageHostConfig().MaxShardSize {
+		t.Fatalf("unexpected StorageHost: %+v", cfg.StorageHost)
+	}
+	if err := cfg.StorageHost.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if migrate_13_HostContractManager(cfg) || migrate_20_StorageHost(cfg) {
+		t.Fatal("expected migrations to be idempotent")
+	}
+
+	cfg = new(Config)
+	if migrate_20_StorageHost(cfg) || !unset(&cfg.StorageHost) {
+		t.Fatal("expected the section to be left unset on nodes that are not hosts")
+	}
+	cfg.Experimental.StorageHostEnabled = true
+	if !migrate_20_StorageHost(cfg) || !reflect.DeepEqual(cfg.StorageHost, DefaultStorageHostConfig()) {
+		t.Fatalf("expected the defaults on storage hosts, got %+v", cfg.StorageHost)
+	}
+}
+
+func TestMigrateStorageClient(t *testing.T) {
+	cfg := new(Config)
+	if migrate_21_StorageClient(cfg) || !unset(&cfg.StorageClient) {
+		t.Fatal("expected the section to be left unset on nodes that are not clients")
+	}
+	cfg.Experimental.StorageClientEnabled = true
+	if !migrate_21_StorageClient(cfg) || !reflect.DeepEqual(cfg.StorageClient, DefaultStorageClientConfig()) {
+		t.Fatalf("expected the defaults on storage clients, got %+v", cfg.StorageClient)
+	}
+
+	cfg = new(Config)
+	cfg.StorageClient.Budget = 1000
+	if !migrate_21_StorageClient(cfg) {
+		t.Fatal("expected a partial section to be completed")
+	}
+	if cfg.StorageClient.Budget != 1000 || cfg.StorageClient.ParityShards != 0 || cfg.StorageClient.DataShards != DefaultStorageClientConfig().DataShards {
+		t.Fatalf("unexpected StorageClient: %+v", cfg.StorageClient)
+	}
+	if err := cfg.StorageClient.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if migrate_21_StorageClient(cfg) {
+		t.Fatal("expected migration to be idempotent")
+	}
+}
+
+func TestMigrateHostsSyncMode(t *testing.T) {
+	cfg := new(Config)
+	cfg.Swarm.SwarmKey = DefaultSwarmKey
+	cfg.Experimental.HostsSyncMode = HostsSyncMode(DefaultHostsSyncModeDev)
+	if migrate_22_HostsSyncMode(cfg) {
+		t.Fatal("expected TESTNET to be kept on the production network")
+	}
+	if w := cfg.Warnings(); len(w) != 1 || !strings.Contains(w[0], "TESTNET") {
+		t.Fatalf("expected a warning for TESTNET on the production network, got %v", w)
+	}
+
+	cfg.Swarm.SwarmKey = DefaultTestnetSwarmKey
+	if migrate_22_HostsSyncMode(cfg) || len(cfg.Warnings()) != 0 {
+		t.Fatal("expected TESTNET to be kept silently on testnet")
+	}
+
+	// an unknown stored mode loads, and is reset to the network default
+	if err := json.Unmarshal([]byte(`{"HostsSyncMode": "FASTEST"}`), &cfg.Experimental); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"Modes": {"fastest": {"MaxHosts": 10}, "geo": {"MaxHosts": 20}}}`), &cfg.HostsSync); err != nil {
+		t.Fatal(err)
+	}
+	if !migrate_22_HostsSyncMode(cfg) {
+		t.Fatal("expected an unknown mode to be reset")
+	}
+	if cfg.Experimental.HostsSyncMode != HostsSyncMode(DefaultHostsSyncModeDev) {
+		t.Fatalf("unexpected mode: %s", cfg.Experimental.HostsSyncMode)
+	}
+	if len(cfg.HostsSync.Modes) != 1 || cfg.HostsSync.Modes[HostsSyncMode(hubpb.HostsReq_GEO)].MaxHosts != 20 {
+		t.Fatalf("unexpected overrides: %v", cfg.HostsSync.Modes)
+	}
+	if err := cfg.HostsSync.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateStorageWorkers(t *testing.T) {
+	cfg := new(Config)
+	cfg.Services = DefaultServicesConfigTestnet()
+	if migrate_23_StorageWorkers(cfg) || !unset(&cfg.Repair) || !unset(&cfg.Challenge) {
+		t.Fatal("expected the sections to be left unset with the features disabled")
+	}
+
+	cfg.Experimental.HostRepairEnabled = true
+	cfg.Challenge.Concurrency = 3
+	cfg.Challenge.MaxBandwidth = "1MB"
+	if !migrate_23_StorageWorkers(cfg) {
+		t.Fatal("expected Repair to be filled in")
+	}
+	if !reflect.DeepEqual(cfg.Repair, DefaultRepairConfigTestnet()) {
+		t.Fatalf("expected testnet defaults, got %+v", cfg.Repair)
+	}
+	defaults := DefaultChallengeConfigTestnet()
+	if cfg.Challenge.Concurrency != 3 || cfg.Challenge.MaxBandwidth != "1MB" || cfg.Challenge.MinReward != 0 {
+		t.Fatalf("existing Challenge settings were overridden: %+v", cfg.Challenge)
+	}
+	if cfg.Challenge.JobTimeout != defaults.JobTimeout || cfg.Challenge.Interval != defaults.Interval {
+		t.Fatalf("expected the zero Challenge settings to be filled in, got %+v", cfg.Challenge)
+	}
+	if err := cfg.Challenge.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if migrate_23_StorageWorkers(cfg) {
+		t.Fatal("expected migration to be idempotent")
+	}
+}
+
+func TestMigrateEmptyLegacyServices(t *testing.T) {
+	cfg := new(Config)
+	err := json.Unmarshal([]byte(`{"Services": {
+		"StatusServerDomain": "", "HubDomain": "", "EscrowDomain": "", "GuardDomain": "",
+		"ExchangeDomain": "", "SolidityDomain": "", "FullnodeDomain": "", "TrongridDomain": ""
+	}}`), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !migrate_1_Services(cfg) {
+		t.Fatal("expected empty legacy services to be migrated")
+	}
+	if !reflect.DeepEqual(cfg.Services, DefaultServicesConfig()) {
+		t.Fatalf("expected the default services, got %+v", cfg.Services)
+	}
+}
+
+func TestMigrateServicesKeepsDomains(t *testing.T) {
+	cfg := new(Config)
+	cfg.Services = DefaultServicesConfigTestnet()
+	cfg.Services.HubDomain.URL = "https://hub.example.com"
+	cfg.Services.GuardPubKeys = nil
+
+	if !migrate_1_Services(cfg) {
+		t.Fatal("expected the missing keys to be filled in")
+	}
+	if cfg.Services.HubDomain.URL != "https://hub.example.com" {
+		t.Fatal("expected the other services to be kept")
+	}
+	if !reflect.DeepEqual(cfg.Services.GuardPubKeys, DefaultServicesConfigTestnet().GuardPubKeys) {
+		t.Fatalf("expected testnet guard keys, got %+v", cfg.Services.GuardPubKeys)
+	}
+	if migrate_1_Services(cfg) {
+		t.Fatal("expected migration to be idempotent")
+	}
+}
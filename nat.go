@@ -0,0 +1,167 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DefaultHolePunching is the default value for NAT.HolePunching
+const DefaultHolePunching = true
+
+// NATPortMapping selects the protocol used to ask the local router for a port
+// mapping.
+type NATPortMapping int
+
+const (
+	// NATPortMappingAuto tries both UPnP and NAT-PMP. This is the default.
+	NATPortMappingAuto NATPortMapping = iota
+	// NATPortMappingUPnP only uses UPnP.
+	NATPortMappingUPnP
+	// NATPortMappingNATPMP only uses NAT-PMP.
+	NATPortMappingNATPMP
+	// NATPortMappingNone disables port mapping.
+	NATPortMappingNone
+)
+
+func (m *NATPortMapping) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "", "auto":
+		*m = NATPortMappingAuto
+	case "upnp":
+		*m = NATPortMappingUPnP
+	case "nat-pmp":
+		*m = NATPortMappingNATPMP
+	case "none":
+		*m = NATPortMappingNone
+	default:
+		return fmt.Errorf("unknown port mapping protocol: %s", string(text))
+	}
+	return nil
+}
+
+func (m NATPortMapping) MarshalText() ([]byte, error) {
+	switch m {
+	case NATPortMappingAuto:
+		return nil, nil
+	case NATPortMappingUPnP:
+		return []byte("upnp"), nil
+	case NATPortMappingNATPMP:
+		return []byte("nat-pmp"), nil
+	case NATPortMappingNone:
+		return []byte("none"), nil
+	default:
+		return nil, fmt.Errorf("unknown port mapping protocol: %d", m)
+	}
+}
+
+// Reachability overrides the reachability the node would otherwise detect
+// through AutoNAT.
+type Reachability int
+
+const (
+	// ReachabilityUnknown lets AutoNAT determine the reachability.
+	ReachabilityUnknown Reachability = iota
+	// ReachabilityPublic forces the node to consider itself publicly
+	// reachable.
+	ReachabilityPublic
+	// ReachabilityPrivate forces the node to consider itself behind a NAT.
+	ReachabilityPrivate
+)
+
+func (r *Reachability) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "":
+		*r = ReachabilityUnknown
+	case "public":
+		*r = ReachabilityPublic
+	case "private":
+		*r = ReachabilityPrivate
+	default:
+		return fmt.Errorf("unknown reachability: %s", string(text))
+	}
+	return nil
+}
+
+func (r Reachability) MarshalText() ([]byte, error) {
+	switch r {
+	case ReachabilityUnknown:
+		return nil, nil
+	case ReachabilityPublic:
+		return []byte("public"), nil
+	case ReachabilityPrivate:
+		return []byte("private"), nil
+	default:
+		return nil, fmt.Errorf("unknown reachability: %d", r)
+	}
+}
+
+// NATConfig configures how the node traverses NATs.
+type NATConfig struct {
+	// HolePunching enables DCUtR hole punching, upgrading relayed
+	// connections to direct ones. Defaults to on.
+	HolePunching Flag `json:",omitempty"`
+
+	// PortMapping selects the protocol used to open the swarm ports on the
+	// router. It is forced to "none" by the legacy
+	// Swarm.DisableNatPortMap flag.
+	PortMapping NATPortMapping `json:",omitempty"`
+
+	// StaticMappings lists ports forwarded manually on the router. Each
+	// internal port must be one of the swarm listening ports.
+	StaticMappings []NATStaticMapping `json:",omitempty"`
+
+	// Reachability overrides the detected reachability.
+	Reachability Reachability `json:",omitempty"`
+}
+
+// NATStaticMapping maps an external port on the router to an internal swarm
+// listening port.
+type NATStaticMapping struct {
+	// Protocol is either "tcp" or "udp".
+	Protocol     string
+	ExternalPort int
+	InternalPort int
+}
+
+// EffectivePortMapping resolves the port mapping protocol, taking the legacy
+// DisableNatPortMap flag into account.
+func (s *SwarmConfig) EffectivePortMapping() NATPortMapping {
+	if s.DisableNatPortMap {
+		return NATPortMappingNone
+	}
+	return s.NAT.PortMapping
+}
+
+// Validate checks the static mappings against the swarm listening addresses.
+func (n *NATConfig) Validate(swarmAddrs []string) error {
+	for _, m := range n.StaticMappings {
+		if m.Protocol != "tcp" && m.Protocol != "udp" {
+			return fmt.Errorf("invalid static mapping protocol %q: must be tcp or udp", m.Protocol)
+		}
+		if m.ExternalPort <= 0 || m.ExternalPort > 65535 {
+			return fmt.Errorf("invalid static mapping external port: %d", m.ExternalPort)
+		}
+		if m.InternalPort <= 0 || m.InternalPort > 65535 {
+			return fmt.Errorf("invalid static mapping internal port: %d", m.InternalPort)
+		}
+		if !listensOnPort(swarmAddrs, m.Protocol, m.InternalPort) {
+			return fmt.Errorf("internal port not found in swarm listening addresses: %s/%d", m.Protocol, m.InternalPort)
+		}
+	}
+	return nil
+}
+
+// listensOnPort reports whether any of the swarm addresses listens on the
+// given tcp or udp port.
+func listensOnPort(swarmAddrs []string, protocol string, port int) bool {
+	for _, sa := range swarmAddrs {
+		parts := strings.Split(sa, "/")
+		for i := 0; i+1 < len(parts); i++ {
+			if parts[i] == protocol && parts[i+1] == strconv.Itoa(port) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestNATConfigJSON(t *testing.T) {
+	var n NATConfig
+	input := `{"HolePunching":false,"PortMapping":"nat-pmp","Reachability":"private"}`
+	if err := json.Unmarshal([]byte(input), &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.HolePunching.WithDefault(DefaultHolePunching) {
+		t.Fatal("expected hole punching to be disabled")
+	}
+	if n.PortMapping != NATPortMappingNATPMP || n.Reachability != ReachabilityPrivate {
+		t.Fatalf("unexpected NAT config: %+v", n)
+	}
+
+	out, err := json.Marshal(NATConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "{}" {
+		t.Fatalf("expected defaults to be omitted, got %s", out)
+	}
+
+	if err := json.Unmarshal([]byte(`{"PortMapping":"pcp"}`), &n); err == nil {
+		t.Fatal("expected unknown port mapping protocol to be rejected")
+	}
+}
+
+func TestNATStaticMappings(t *testing.T) {
+	swarm := []string{
+		"/ip4/0.0.0.0/tcp/4001",
+		"/ip4/0.0.0.0/udp/4001/quic-v1",
+	}
+	n := NATConfig{StaticMappings: []NATStaticMapping{
+		{Protocol: "tcp", ExternalPort: 14001, InternalPort: 4001},
+		{Protocol: "udp", ExternalPort: 14001, InternalPort: 4001},
+	}}
+	if err := n.Validate(swarm); err != nil {
+		t.Fatal(err)
+	}
+
+	n.StaticMappings[0].InternalPort = 4002
+	if err := n.Validate(swarm); err == nil {
+		t.Fatal("expected unknown internal port to be rejected")
+	}
+
+	n.StaticMappings[0] = NATStaticMapping{Protocol: "sctp", ExternalPort: 1, InternalPort: 4001}
+	if err := n.Validate(swarm); err == nil {
+		t.Fatal("expected unknown protocol to be rejected")
+	}
+}
+
+func TestEffectivePortMapping(t *testing.T) {
+	s := SwarmConfig{NAT: NATConfig{PortMapping: NATPortMappingUPnP}}
+	if s.EffectivePortMapping() != NATPortMappingUPnP {
+		t.Fatal("expected upnp")
+	}
+	s.DisableNatPortMap = true
+	if s.EffectivePortMapping() != NATPortMappingNone {
+		t.Fatal("expected DisableNatPortMap to disable port mapping")
+	}
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected contradictory port mapping settings to be rejected")
+	}
+}
+
+func TestNetworkingProfilesKeepNATSettings(t *testing.T) {
+	mappings := []NATStaticMapping{{Protocol: "tcp", ExternalPort: 14001, InternalPort: 4001}}
+	for _, name := range []string{"test", "default-networking"} {
+		c := new(Config)
+		c.Swarm.NAT.StaticMappings = mappings
+		c.Swarm.NAT.Reachability = ReachabilityPublic
+		if err := Profiles[name].Transform(c); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c.Swarm.NAT.StaticMappings, mappings) || c.Swarm.NAT.Reachability != ReachabilityPublic {
+			t.Fatalf("%s: NAT settings not kept: %+v", name, c.Swarm.NAT)
+		}
+	}
+}
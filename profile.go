@@ -24,31 +24,10 @@ type Profile struct {
 	InitOnly bool
 }
 
-// defaultServerFilters has is a list of IPv4 and IPv6 prefixes that are private, local only, or unrouteable.
-// according to https://www.iana.org/assignments/iana-ipv4-special-registry/iana-ipv4-special-registry.xhtml
-// and https://www.iana.org/assignments/iana-ipv6-special-registry/iana-ipv6-special-registry.xhtml
-var defaultServerFilters = []string{
-	"/ip4/10.0.0.0/ipcidr/8",
-	"/ip4/100.64.0.0/ipcidr/10",
-	"/ip4/169.254.0.0/ipcidr/16",
-	"/ip4/172.16.0.0/ipcidr/12",
-	"/ip4/192.0.0.0/ipcidr/24",
-	"/ip4/192.0.0.0/ipcidr/29",
-	"/ip4/192.0.0.8/ipcidr/32",
-	"/ip4/192.0.0.170/ipcidr/32",
-	"/ip4/192.0.0.171/ipcidr/32",
-	"/ip4/192.0.2.0/ipcidr/24",
-	"/ip4/192.168.0.0/ipcidr/16",
-	"/ip4/198.18.0.0/ipcidr/15",
-	"/ip4/198.51.100.0/ipcidr/24",
-	"/ip4/203.0.113.0/ipcidr/24",
-	"/ip4/240.0.0.0/ipcidr/4",
-	"/ip6/100::/ipcidr/64",
-	"/ip6/2001:2::/ipcidr/48",
-	"/ip6/2001:db8::/ipcidr/32",
-	"/ip6/fc00::/ipcidr/7",
-	"/ip6/fe80::/ipcidr/10",
-}
+// defaultServerFilters is the list of private, local only, or unrouteable
+// prefixes from the PrivateAddrsRuleSet gating rule set. It is a copy, so that
+// configs built from it cannot change the rule set.
+var defaultServerFilters = append([]string(nil), gaterRuleSets[PrivateAddrsRuleSet].CIDRs...)
 
 func ExternalIP() (string, error) {
 	return ExternalIPWithPort(DefaultSwarmPort, DefaultSwarmPort, nil)
@@ -100,6 +79,7 @@ running IPFS on machines with public IPv4 addresses.`,
 			c.Swarm.AddrFilters = appendSingle(c.Swarm.AddrFilters, defaultServerFilters)
 			c.Discovery.MDNS.Enabled = false
 			c.Swarm.DisableNatPortMap = true
+			c.Swarm.NAT.PortMapping = NATPortMappingNone
 			return nil
 		},
 	},
@@ -113,6 +93,7 @@ profile, enables discovery in local networks.`,
 			c.Swarm.AddrFilters = deleteEntries(c.Swarm.AddrFilters, defaultServerFilters)
 			c.Discovery.MDNS.Enabled = true
 			c.Swarm.DisableNatPortMap = false
+			c.Swarm.NAT.PortMapping = NATPortMappingAuto
 			return nil
 		},
 	},
@@ -128,6 +109,8 @@ is useful when using the daemon in test environments.`,
 			}
 
 			c.Swarm.DisableNatPortMap = true
+			c.Swarm.NAT.HolePunching = False
+			c.Swarm.NAT.PortMapping = NATPortMappingNone
 
 			c.Bootstrap = []string{}
 			c.Discovery.MDNS.Enabled = false
@@ -148,6 +131,8 @@ Inverse profile of the test profile.`,
 			c.Bootstrap = appendSingle(c.Bootstrap, BootstrapPeerStrings(bootstrapPeers))
 
 			c.Swarm.DisableNatPortMap = false
+			c.Swarm.NAT.HolePunching = Default
+			c.Swarm.NAT.PortMapping = NATPortMappingAuto
 			c.Discovery.MDNS.Enabled = true
 			return nil
 		},
@@ -223,6 +208,46 @@ This profile may only be applied when first initializing the node.`,
 			return nil
 		},
 	},
+	"pebbleds": {
+		Description: `Configures the node to use the pebble datastore.
+
+Pebble performs like badger on large datastores while using less memory, and
+reclaims space after garbage collection. It is recommended for large storage
+hosts.
+
+This profile may only be applied when first initializing the node.`,
+
+		InitOnly: true,
+		Transform: func(c *Config) error {
+			c.Datastore.Spec = DefaultPebbleSpec().SpecMap()
+			return nil
+		},
+	},
+	"s3ds": {
+		Description: `Configures the node to store blocks in an S3-compatible bucket.
+
+The bucket is read from Datastore.S3. As the profile may only be applied on
+init, set Datastore.S3 in the config file passed to init along with the
+profile, e.g. 'btfs init --profile=s3ds btfs-config.json'. Set its Endpoint
+to use an S3-compatible service such as MinIO. Credentials are taken from
+the environment of the daemon.
+
+This profile may only be applied when first initializing the node.`,
+
+		InitOnly: true,
+		Transform: func(c *Config) error {
+			s3 := c.Datastore.S3
+			if s3 == nil || s3.Bucket == "" {
+				return fmt.Errorf("Datastore.S3.Bucket must be set")
+			}
+			spec := DefaultS3Spec(s3.Bucket, s3.Region, s3.Endpoint)
+			if err := spec.Validate(); err != nil {
+				return err
+			}
+			c.Datastore.Spec = spec.SpecMap()
+			return nil
+		},
+	},
 	"lowpower": {
 		Description: `Reduces daemon overhead on the system. May affect node
 functionality - performance of content discovery and data
@@ -239,6 +264,17 @@ fetching may be degraded.
 			return nil
 		},
 	},
+	"auto": {
+		Description: `Tunes the connection manager, bloom filter, StorageMax,
+reprovider interval and datastore to the CPUs, memory and free disk of
+the machine. Use AutoTune directly to also get the report of the
+decisions.
+
+This profile may only be applied when first initializing the node.`,
+
+		InitOnly:  true,
+		Transform: autoProfile(ReadHostResources),
+	},
 	"randomports": {
 		Description: `Use a random port number for swarm.`,
 
@@ -304,6 +340,7 @@ fetching may be degraded.
 				return err
 			}
 			c.Services = DefaultServicesConfigDev()
+			c.Repair = DefaultRepairConfigDev()
 			return nil
 		},
 	},
@@ -315,6 +352,7 @@ fetching may be degraded.
 				return err
 			}
 			c.Services = DefaultServicesConfigTestnet()
+			c.Repair = DefaultRepairConfigTestnet()
 			return nil
 		},
 	},
@@ -327,13 +365,14 @@ fetching may be degraded.
 				return err
 			}
 			c.Bootstrap = BootstrapPeerStrings(bootstrapPeers)
-			c.Experimental.Libp2pStreamMounting = true
-			c.Experimental.HostChallengeEnabled = true
-			c.Experimental.Analytics = true
+			if err := c.Experimental.Enable("host-challenge", "analytics"); err != nil {
+				return err
+			}
 			if len(c.Addresses.RemoteAPI) == 0 {
 				c.Addresses.RemoteAPI = Strings{"/ip4/0.0.0.0/tcp/5101"}
 			}
 			c.Services = DefaultServicesConfig()
+			c.Challenge = DefaultChallengeConfig()
 			c.Swarm.SwarmKey = DefaultSwarmKey
 			return nil
 		},
@@ -346,6 +385,7 @@ fetching may be degraded.
 				return err
 			}
 			c.Services = DefaultServicesConfigDev()
+			c.Challenge = DefaultChallengeConfigDev()
 			return nil
 		},
 	},
@@ -357,6 +397,7 @@ fetching may be degraded.
 				return err
 			}
 			c.Services = DefaultServicesConfigTestnet()
+			c.Challenge = DefaultChallengeConfigTestnet()
 			return nil
 		},
 	},
@@ -369,10 +410,13 @@ fetching may be degraded.
 				return err
 			}
 			c.Bootstrap = BootstrapPeerStrings(bootstrapPeers)
-			c.Experimental.Libp2pStreamMounting = true
-			c.Experimental.StorageClientEnabled = true
-			c.Experimental.HostsSyncEnabled = true
-			c.Experimental.HostsSyncMode = DefaultHostsSyncMode.String()
+			if err := c.Experimental.Enable("storage-client"); err != nil {
+				return err
+			}
+			if c.StorageClient.DataShards == 0 {
+				c.StorageClient = DefaultStorageClientConfig()
+			}
+			c.Experimental.HostsSyncMode = c.StorageClient.HubMode(false)
 			if len(c.Addresses.RemoteAPI) == 0 {
 				c.Addresses.RemoteAPI = Strings{"/ip4/0.0.0.0/tcp/5101"}
 			}
@@ -411,28 +455,33 @@ func transformDefaultStorageHost(c *Config) error {
 		return err
 	}
 	c.Bootstrap = BootstrapPeerStrings(bootstrapPeers)
-	c.Experimental.Libp2pStreamMounting = true
-	c.Experimental.StorageHostEnabled = true
-	c.Experimental.Analytics = true
+	if err := c.Experimental.Enable("storage-host", "analytics"); err != nil {
+		return err
+	}
 	if len(c.Addresses.RemoteAPI) == 0 {
 		c.Addresses.RemoteAPI = Strings{"/ip4/0.0.0.0/tcp/5101"}
 	}
 	if c.Datastore.StorageMax == "10GB" {
 		c.Datastore.StorageMax = "1TB"
 	}
+	if c.Datastore.GC == nil {
+		c.Datastore.GC = hostGCPolicy()
+	}
 	c.Services = DefaultServicesConfig()
 	c.Swarm.SwarmKey = DefaultSwarmKey
 	return nil
 }
 
 func transformDefaultStorageRepairer(c *Config) error {
-	c.Experimental.HostRepairEnabled = true
-	c.Experimental.HostsSyncEnabled = true
-	c.Experimental.HostsSyncMode = DefaultHostsSyncMode.String()
+	if err := c.Experimental.Enable("host-repair"); err != nil {
+		return err
+	}
+	c.Experimental.HostsSyncMode = HostsSyncMode(DefaultHostsSyncMode)
 	err := transformDefaultStorageHost(c)
 	if err != nil {
 		return err
 	}
+	c.Repair = DefaultRepairConfig()
 	return nil
 }
 
@@ -443,15 +492,18 @@ func transformDevStorageHost(c *Config) error {
 		return err
 	}
 	c.Bootstrap = BootstrapPeerStrings(bootstrapPeers)
-	c.Experimental.Libp2pStreamMounting = true
-	c.Experimental.StorageHostEnabled = true
-	c.Experimental.Analytics = true
+	if err := c.Experimental.Enable("storage-host", "analytics"); err != nil {
+		return err
+	}
 	if len(c.Addresses.RemoteAPI) == 0 {
 		c.Addresses.RemoteAPI = Strings{"/ip4/0.0.0.0/tcp/5101"}
 	}
 	if c.Datastore.StorageMax == "10GB" {
 		c.Datastore.StorageMax = "1TB"
 	}
+	if c.Datastore.GC == nil {
+		c.Datastore.GC = hostGCPolicy()
+	}
 	c.Services = DefaultServicesConfigDev()
 	c.Swarm.SwarmKey = DefaultTestnetSwarmKey
 	return nil
@@ -459,9 +511,10 @@ func transformDevStorageHost(c *Config) error {
 
 // transformDevStorageRepairer transforms common repairer settings among different dev environments
 func transformDevStorageRepairer(c *Config) error {
-	c.Experimental.HostRepairEnabled = true
-	c.Experimental.HostsSyncEnabled = true
-	c.Experimental.HostsSyncMode = DefaultHostsSyncModeDev.String()
+	if err := c.Experimental.Enable("host-repair"); err != nil {
+		return err
+	}
+	c.Experimental.HostsSyncMode = HostsSyncMode(DefaultHostsSyncModeDev)
 	err := transformDevStorageHost(c)
 	if err != nil {
 		return err
@@ -476,9 +529,9 @@ func transformDevStorageChallenger(c *Config) error {
 		return err
 	}
 	c.Bootstrap = BootstrapPeerStrings(bootstrapPeers)
-	c.Experimental.Libp2pStreamMounting = true
-	c.Experimental.HostChallengeEnabled = true
-	c.Experimental.Analytics = true
+	if err := c.Experimental.Enable("host-challenge", "analytics"); err != nil {
+		return err
+	}
 	if len(c.Addresses.RemoteAPI) == 0 {
 		c.Addresses.RemoteAPI = Strings{"/ip4/0.0.0.0/tcp/5101"}
 	}
@@ -493,10 +546,13 @@ func transformDevStorageClient(c *Config) error {
 		return err
 	}
 	c.Bootstrap = BootstrapPeerStrings(bootstrapPeers)
-	c.Experimental.Libp2pStreamMounting = true
-	c.Experimental.StorageClientEnabled = true
-	c.Experimental.HostsSyncEnabled = true
-	c.Experimental.HostsSyncMode = DefaultHostsSyncModeDev.String()
+	if err := c.Experimental.Enable("storage-client"); err != nil {
+		return err
+	}
+	if c.StorageClient.DataShards == 0 {
+		c.StorageClient = DefaultStorageClientConfig()
+	}
+	c.Experimental.HostsSyncMode = c.StorageClient.HubMode(true)
 	if len(c.Addresses.RemoteAPI) == 0 {
 		c.Addresses.RemoteAPI = Strings{"/ip4/0.0.0.0/tcp/5101"}
 	}
@@ -504,6 +560,11 @@ func transformDevStorageClient(c *Config) error {
 	return nil
 }
 
+// hostGCPolicy keeps contract-held and user-pinned blocks on storage hosts.
+func hostGCPolicy() *GCPolicy {
+	return &GCPolicy{Protected: []GCPinCategory{GCPinContract, GCPinUser}}
+}
+
 func getAvailablePort() (port int, err error) {
 	ln, err := net.Listen("tcp", "[::]:0")
 	if err != nil {
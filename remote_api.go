@@ -0,0 +1,281 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+const (
+	// RemoteAPIServiceEscrow grants a role to the holders of
+	// Services.EscrowPubKeys.
+	RemoteAPIServiceEscrow = "escrow"
+	// RemoteAPIServiceGuard grants a role to the holders of
+	// Services.GuardPubKeys.
+	RemoteAPIServiceGuard = "guard"
+)
+
+// RemoteAPIPolicy restricts which peers may call which commands on the API
+// mounted over libp2p at Addresses.RemoteAPI. The zero value allows every
+// peer to call every command.
+type RemoteAPIPolicy struct {
+	// AllowedPeers, when not empty, restricts callers to the listed peer
+	// IDs and to peers holding a role.
+	AllowedPeers []string `json:",omitempty"`
+
+	// Commands lists the command patterns, such as "storage/upload/*",
+	// that callers may use without a role. Every command is allowed when
+	// empty.
+	Commands []string `json:",omitempty"`
+
+	// RateLimit limits each caller without a role.
+	RateLimit *RemoteAPIRateLimit `json:",omitempty"`
+
+	// Roles grants privileged access, keyed by role name.
+	Roles map[string]*RemoteAPIRole `json:",omitempty"`
+}
+
+// restricted reports whether the policy limits callers without a role. Roles
+// only grant access, so they do not restrict on their own.
+func (p *RemoteAPIPolicy) restricted() bool {
+	return len(p.AllowedPeers) > 0 || len(p.Commands) > 0
+}
+
+// RemoteAPIRole grants a set of peers access to a set of commands.
+type RemoteAPIRole struct {
+	// Peers lists the peer IDs holding the role.
+	Peers []string `json:",omitempty"`
+
+	// ServiceKeys grants the role to the peers derived from the public
+	// keys of the listed services: "escrow" or "guard".
+	ServiceKeys []string `json:",omitempty"`
+
+	// Commands lists the command patterns the role may call.
+	Commands []string
+
+	// RateLimit limits each peer holding the role. Unlimited when unset.
+	RateLimit *RemoteAPIRateLimit `json:",omitempty"`
+}
+
+// RemoteAPIRateLimit limits the request rate of a single peer.
+type RemoteAPIRateLimit struct {
+	RequestsPerMinute int
+	Burst             int `json:",omitempty"`
+}
+
+// RemoteAPIDecision is the outcome of RemoteAPIAuthorizer.Authorize.
+type RemoteAPIDecision struct {
+	Allowed bool
+	// Role is the role that granted access, empty for callers without a
+	// role.
+	Role string
+	// RateLimit is the rate limit to apply to the caller, nil if none.
+	RateLimit *RemoteAPIRateLimit
+}
+
+// RemoteAPIAuthorizer evaluates a RemoteAPIPolicy.
+type RemoteAPIAuthorizer struct {
+	restricted   bool
+	allowedPeers map[peer.ID]struct{}
+	commands     []string
+	rateLimit    *RemoteAPIRateLimit
+	roles        []remoteAPIRole
+}
+
+type remoteAPIRole struct {
+	name  string
+	peers map[peer.ID]struct{}
+	// keys holds the service keys of each peer granted the role by
+	// ServiceKeys, checked against their validity window on each call.
+	keys      map[peer.ID][]ServiceKey
+	commands  []string
+	rateLimit *RemoteAPIRateLimit
+}
+
+// heldBy reports whether peer p holds the role at t.
+func (r *remoteAPIRole) heldBy(p peer.ID, t time.Time) bool {
+	if _, ok := r.peers[p]; ok {
+		return true
+	}
+	for i := range r.keys[p] {
+		if r.keys[p][i].ValidAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRemoteAPIAuthorizer builds a RemoteAPIAuthorizer from the RemoteAPI
+// policy, resolving service key roles against Services.
+func NewRemoteAPIAuthorizer(c *Config) (*RemoteAPIAuthorizer, error) {
+	p := &c.RemoteAPI
+	a := &RemoteAPIAuthorizer{
+		restricted: len(p.AllowedPeers) > 0,
+		commands:   p.Commands,
+		rateLimit:  p.RateLimit,
+	}
+	var err error
+	if a.allowedPeers, err = decodePeerIDs(p.AllowedPeers, nil); err != nil {
+		return nil, fmt.Errorf("AllowedPeers: %s", err)
+	}
+	if err := validateRateLimit(p.RateLimit); err != nil {
+		return nil, err
+	}
+	if err := validateCommandPatterns(p.Commands); err != nil {
+		return nil, fmt.Errorf("Commands: %s", err)
+	}
+
+	names := make([]string, 0, len(p.Roles))
+	for name := range p.Roles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		role := p.Roles[name]
+		if role == nil {
+			return nil, fmt.Errorf("Roles[%s]: missing role", name)
+		}
+		peers, err := decodePeerIDs(role.Peers, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Roles[%s]: %s", name, err)
+		}
+		keyPeers := make(map[peer.ID][]ServiceKey)
+		for _, service := range role.ServiceKeys {
+			var keys ServiceKeys
+			switch service {
+			case RemoteAPIServiceEscrow:
+				keys = c.Services.EscrowPubKeys
+			case RemoteAPIServiceGuard:
+				keys = c.Services.GuardPubKeys
+			default:
+				return nil, fmt.Errorf("Roles[%s]: unknown service %q", name, service)
+			}
+			if err := servicePeerIDs(keys, keyPeers); err != nil {
+				return nil, fmt.Errorf("Roles[%s]: %s keys: %s", name, service, err)
+			}
+		}
+		if len(role.Commands) == 0 {
+			return nil, fmt.Errorf("Roles[%s]: Commands must not be empty", name)
+		}
+		if err := validateCommandPatterns(role.Commands); err != nil {
+			return nil, fmt.Errorf("Roles[%s]: Commands: %s", name, err)
+		}
+		if err := validateRateLimit(role.RateLimit); err != nil {
+			return nil, fmt.Errorf("Roles[%s]: %s", name, err)
+		}
+		a.roles = append(a.roles, remoteAPIRole{
+			name:      name,
+			peers:     peers,
+			keys:      keyPeers,
+			commands:  role.Commands,
+			rateLimit: role.RateLimit,
+		})
+	}
+	return a, nil
+}
+
+func decodePeerIDs(ids []string, out map[peer.ID]struct{}) (map[peer.ID]struct{}, error) {
+	if out == nil {
+		out = make(map[peer.ID]struct{}, len(ids))
+	}
+	for _, s := range ids {
+		id, err := peer.Decode(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID %q: %s", s, err)
+		}
+		out[id] = struct{}{}
+	}
+	return out, nil
+}
+
+// servicePeerIDs adds the service keys that are not revoked to out, keyed by
+// their peer ID. Their validity windows are left to Authorize, since the
+// authorizer outlives the time it is built at.
+func servicePeerIDs(keys ServiceKeys, out map[peer.ID][]ServiceKey) error {
+	for i := range keys {
+		if keys[i].Status == ServiceKeyRevoked {
+			continue
+		}
+		pk, err := keys[i].PubKey()
+		if err != nil {
+			return err
+		}
+		id, err := peer.IDFromPublicKey(pk)
+		if err != nil {
+			return err
+		}
+		out[id] = append(out[id], keys[i])
+	}
+	return nil
+}
+
+func validateCommandPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if err := validateCommandPattern(pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRateLimit(rl *RemoteAPIRateLimit) error {
+	if rl != nil && (rl.RequestsPerMinute <= 0 || rl.Burst < 0) {
+		return fmt.Errorf("RateLimit must be positive")
+	}
+	return nil
+}
+
+func anyCommandMatches(patterns []string, command string) bool {
+	for _, pattern := range patterns {
+		if commandMatches(pattern, command) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize decides whether peer p may call the command, given as a path
+// with or without the /api/v1/ prefix. Roles are tried in name order before
+// the rules for callers without a role, which also apply to role holders
+// outside AllowedPeers. Paths with empty, "." or ".." segments are denied.
+// Roles granted by service keys only hold within the validity window of the
+// keys.
+func (a *RemoteAPIAuthorizer) Authorize(p peer.ID, command string) RemoteAPIDecision {
+	return a.AuthorizeAt(p, command, time.Now())
+}
+
+// AuthorizeAt is Authorize with service key windows checked at t.
+func (a *RemoteAPIAuthorizer) AuthorizeAt(p peer.ID, command string, t time.Time) RemoteAPIDecision {
+	if _, ok := commandSegments(command); !ok {
+		return RemoteAPIDecision{}
+	}
+	command = strings.TrimPrefix(command, APIPathPrefix)
+	if strings.Trim(command, "/") == "" {
+		return RemoteAPIDecision{}
+	}
+
+	holdsRole := false
+	for i := range a.roles {
+		role := &a.roles[i]
+		if !role.heldBy(p, t) {
+			continue
+		}
+		holdsRole = true
+		if anyCommandMatches(role.commands, command) {
+			return RemoteAPIDecision{Allowed: true, Role: role.name, RateLimit: role.rateLimit}
+		}
+	}
+
+	if a.restricted && !holdsRole {
+		if _, ok := a.allowedPeers[p]; !ok {
+			return RemoteAPIDecision{}
+		}
+	}
+	if len(a.commands) > 0 && !anyCommandMatches(a.commands, command) {
+		return RemoteAPIDecision{}
+	}
+	return RemoteAPIDecision{Allowed: true, RateLimit: a.rateLimit}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+func TestRemoteAPIAuthorizer(t *testing.T) {
+	c := new(Config)
+	c.Services = DefaultServicesConfig()
+	c.RemoteAPI = RemoteAPIPolicy{
+		Commands:  []string{"storage/upload/*", "id"},
+		RateLimit: &RemoteAPIRateLimit{RequestsPerMinute: 60},
+		Roles: map[string]*RemoteAPIRole{
+			"guard": {
+				ServiceKeys: []string{RemoteAPIServiceGuard},
+				Commands:    []string{"storage/challenge/*"},
+			},
+			"operator": {
+				Peers:     []string{testPeerA},
+				Commands:  []string{"*"},
+				RateLimit: &RemoteAPIRateLimit{RequestsPerMinute: 600, Burst: 10},
+			},
+		},
+	}
+	a, err := NewRemoteAPIAuthorizer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guards := map[peer.ID][]ServiceKey{}
+	if err := servicePeerIDs(c.Services.GuardPubKeys, guards); err != nil {
+		t.Fatal(err)
+	}
+	var guard peer.ID
+	for id := range guards {
+		guard = id
+	}
+	operator, other := mustPeer(t, testPeerA), mustPeer(t, testPeerB)
+
+	for _, tc := range []struct {
+		peer    peer.ID
+		command string
+		allowed bool
+		role    string
+	}{
+		{guard, "/api/v1/storage/challenge/response", true, "guard"},
+		{guard, "storage/upload/init", true, ""},
+		{other, "/api/v1/storage/challenge/response", false, ""},
+		{other, "/api/v1/storage/upload/init", true, ""},
+		{other, "config/show", false, ""},
+		{operator, "config/show", true, "operator"},
+	} {
+		d := a.Authorize(tc.peer, tc.command)
+		if d.Allowed != tc.allowed || d.Role != tc.role {
+			t.Fatalf("%s %s: expected allowed=%v role=%q, got %+v", tc.peer, tc.command, tc.allowed, tc.role, d)
+		}
+	}
+
+	if d := a.Authorize(other, "id"); d.RateLimit == nil || d.RateLimit.RequestsPerMinute != 60 {
+		t.Fatalf("expected default rate limit, got %+v", d.RateLimit)
+	}
+
+	c.RemoteAPI.AllowedPeers = []string{testPeerA}
+	if a, err = NewRemoteAPIAuthorizer(c); err != nil {
+		t.Fatal(err)
+	}
+	if a.Authorize(other, "id").Allowed {
+		t.Fatal("expected peer outside AllowedPeers to be rejected")
+	}
+	if !a.Authorize(guard, "storage/challenge/response").Allowed {
+		t.Fatal("expected role holder outside AllowedPeers to be allowed")
+	}
+	if d := a.Authorize(guard, "id"); !d.Allowed || d.Role != "" {
+		t.Fatalf("expected role holder outside AllowedPeers to get the default commands, got %+v", d)
+	}
+	if a.Authorize(guard, "config/show").Allowed {
+		t.Fatal("expected role holder to be limited to the default commands")
+	}
+	for _, command := range []string{
+		"storage/challenge/../../config/show",
+		"/api/v1/storage/upload/../../config/show",
+		"storage/upload//init",
+		"/api/v1//id",
+		"./id",
+	} {
+		if a.Authorize(operator, command).Allowed || a.Authorize(guard, command).Allowed {
+			t.Fatalf("%s: expected unclean path to be denied", command)
+		}
+	}
+
+	var open RemoteAPIAuthorizer
+	if !open.Authorize(other, "anything").Allowed {
+		t.Fatal("expected the empty policy to allow everything")
+	}
+
+	c.RemoteAPI.Roles["broken"] = &RemoteAPIRole{ServiceKeys: []string{"hub"}, Commands: []string{"*"}}
+	if _, err := NewRemoteAPIAuthorizer(c); err == nil {
+		t.Fatal("expected unknown service to be rejected")
+	}
+	delete(c.RemoteAPI.Roles, "broken")
+
+	for _, pattern := range []string{"", "storage/*/init", "storage/../id", "files/./ls", "files//ls", "cat*"} {
+		c.RemoteAPI.Commands = []string{pattern}
+		if _, err := NewRemoteAPIAuthorizer(c); err == nil {
+			t.Fatalf("%q: expected invalid command pattern to be rejected", pattern)
+		}
+		c.RemoteAPI.Commands = nil
+		c.RemoteAPI.Roles["broken"] = &RemoteAPIRole{Commands: []string{pattern}}
+		if _, err := NewRemoteAPIAuthorizer(c); err == nil {
+			t.Fatalf("%q: expected invalid role command pattern to be rejected", pattern)
+		}
+		delete(c.RemoteAPI.Roles, "broken")
+	}
+}
+
+func TestRemoteAPIServiceKeyWindow(t *testing.T) {
+	sk, key := testServiceKey(t, "guard-1")
+	notBefore := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(30 * 24 * time.Hour)
+	key.NotBefore, key.NotAfter = &notBefore, &notAfter
+
+	c := new(Config)
+	c.Services.GuardPubKeys = ServiceKeys{key}
+	c.RemoteAPI = RemoteAPIPolicy{
+		Commands: []string{"id"},
+		Roles: map[string]*RemoteAPIRole{
+			"guard": {ServiceKeys: []string{RemoteAPIServiceGuard}, Commands: []string{"storage/challenge/*"}},
+		},
+	}
+	a, err := NewRemoteAPIAuthorizer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard, err := peer.IDFromPrivateKey(sk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for at, allowed := range map[time.Time]bool{
+		notBefore.Add(-time.Hour): false,
+		notBefore:                 true,
+		notAfter.Add(-time.Hour):  true,
+		notAfter:                  false,
+	} {
+		if d := a.AuthorizeAt(guard, "storage/challenge/response", at); d.Allowed != allowed {
+			t.Errorf("%s: expected allowed=%v, got %+v", at, allowed, d)
+		}
+	}
+	if !a.AuthorizeAt(guard, "id", notAfter).Allowed {
+		t.Fatal("expected an expired key holder to keep the default commands")
+	}
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+)
+
+// ServiceKeyStatus is the rotation state of a service key.
+type ServiceKeyStatus string
+
+const (
+	// ServiceKeyActive keys sign and verify.
+	ServiceKeyActive ServiceKeyStatus = "active"
+	// ServiceKeyRetiring keys still verify while their replacement is
+	// rolled out, but the service no longer signs with them.
+	ServiceKeyRetiring ServiceKeyStatus = "retiring"
+	// ServiceKeyRevoked keys never verify.
+	ServiceKeyRevoked ServiceKeyStatus = "revoked"
+)
+
+// ServiceKey is a public key of an external service. An active key without
+// an ID or validity window is stored as a bare base64 string, the format used
+// before keys carried rotation metadata, so that older daemons can still read
+// it.
+type ServiceKey struct {
+	// ID names the key. Defaults to the peer ID derived from Key.
+	ID string `json:",omitempty"`
+
+	// Key is the base64 encoded, protobuf serialized libp2p public key.
+	Key string
+
+	// Status defaults to active.
+	Status ServiceKeyStatus `json:",omitempty"`
+
+	// NotBefore and NotAfter bound the validity of the key. Unbounded when
+	// unset.
+	NotBefore *time.Time `json:",omitempty"`
+	NotAfter  *time.Time `json:",omitempty"`
+}
+
+// UnmarshalJSON accepts either a bare base64 key or the key object.
+func (k *ServiceKey) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*k = ServiceKey{Key: value, Status: ServiceKeyActive}
+		return nil
+	}
+	var value serviceKey
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*k = ServiceKey(value)
+	return nil
+}
+
+// MarshalJSON writes a bare base64 key when the key carries no metadata.
+func (k ServiceKey) MarshalJSON() ([]byte, error) {
+	if k.ID == "" && (k.Status == "" || k.Status == ServiceKeyActive) && k.NotBefore == nil && k.NotAfter == nil {
+		return json.Marshal(k.Key)
+	}
+	return json.Marshal(serviceKey(k))
+}
+
+// serviceKey is ServiceKey without its JSON methods.
+type serviceKey ServiceKey
+
+var _ json.Unmarshaler = (*ServiceKey)(nil)
+var _ json.Marshaler = (*ServiceKey)(nil)
+
+// PubKey decodes the public key.
+func (k *ServiceKey) PubKey() (ci.PubKey, error) {
+	b, err := base64.StdEncoding.DecodeString(k.Key)
+	if err != nil {
+		return nil, err
+	}
+	return ci.UnmarshalPublicKey(b)
+}
+
+// KeyID returns the ID of the key, deriving it from the key when unset.
+func (k *ServiceKey) KeyID() (string, error) {
+	if k.ID != "" {
+		return k.ID, nil
+	}
+	pk, err := k.PubKey()
+	if err != nil {
+		return "", err
+	}
+	id, err := peer.IDFromPublicKey(pk)
+	if err != nil {
+		return "", err
+	}
+	return id.String(), nil
+}
+
+// ValidAt reports whether the key may verify signatures at t: it is not
+// revoked and t lies within its validity window.
+func (k *ServiceKey) ValidAt(t time.Time) bool {
+	if k.Status == ServiceKeyRevoked {
+		return false
+	}
+	if k.NotBefore != nil && t.Before(*k.NotBefore) {
+		return false
+	}
+	if k.NotAfter != nil && !t.Before(*k.NotAfter) {
+		return false
+	}
+	return true
+}
+
+// Validate checks that the key decodes and that its metadata is consistent.
+func (k *ServiceKey) Validate() error {
+	switch k.Status {
+	case "", ServiceKeyActive, ServiceKeyRetiring, ServiceKeyRevoked:
+	default:
+		return fmt.Errorf("invalid status %q", k.Status)
+	}
+	if _, err := k.PubKey(); err != nil {
+		return fmt.Errorf("invalid key: %s", err)
+	}
+	if k.NotBefore != nil && k.NotAfter != nil && !k.NotAfter.After(*k.NotBefore) {
+		return fmt.Errorf("NotAfter must be after NotBefore")
+	}
+	return nil
+}
+
+// ServiceKeys is the key set of a service. Rotating keys is done by adding
+// the new key, marking the old one retiring and, once no longer used,
+// revoked.
+type ServiceKeys []ServiceKey
+
+// Validate checks every key and that key IDs are unique.
+func (ks ServiceKeys) Validate() error {
+	ids := make(map[string]struct{}, len(ks))
+	for i := range ks {
+		if err := ks[i].Validate(); err != nil {
+			return fmt.Errorf("key %d: %s", i, err)
+		}
+		id, err := ks[i].KeyID()
+		if err != nil {
+			return fmt.Errorf("key %d: %s", i, err)
+		}
+		if _, ok := ids[id]; ok {
+			return fmt.Errorf("key %d: duplicate key ID %q", i, id)
+		}
+		ids[id] = struct{}{}
+	}
+	return nil
+}
+
+// VerificationSet decodes the keys valid at t, keyed by key ID.
+func (ks ServiceKeys) VerificationSet(t time.Time) (map[string]ci.PubKey, error) {
+	set := make(map[string]ci.PubKey)
+	for i := range ks {
+		if !ks[i].ValidAt(t) {
+			continue
+		}
+		id, err := ks[i].KeyID()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %s", i, err)
+		}
+		pk, err := ks[i].PubKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %s", i, err)
+		}
+		set[id] = pk
+	}
+	return set, nil
+}
+
+// Verify checks sig against the keys valid at t and returns the ID of the
+// key that made it.
+func (ks ServiceKeys) Verify(t time.Time, data, sig []byte) (string, error) {
+	set, err := ks.VerificationSet(t)
+	if err != nil {
+		return "", err
+	}
+	if len(set) == 0 {
+		return "", fmt.Errorf("no key valid at %s", t.Format(time.RFC3339))
+	}
+	for id, pk := range set {
+		if ok, err := pk.Verify(data, sig); err == nil && ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("signature does not match any valid key")
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	ci "github.com/libp2p/go-libp2p-core/crypto"
+)
+
+func testServiceKey(t *testing.T, id string) (ci.PrivKey, ServiceKey) {
+	sk, pk, err := ci.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ci.MarshalPublicKey(pk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sk, ServiceKey{ID: id, Key: base64.StdEncoding.EncodeToString(b), Status: ServiceKeyActive}
+}
+
+func TestServiceKeysDecode(t *testing.T) {
+	var keys ServiceKeys
+	err := json.Unmarshal([]byte(`[
+		"CAISIQPAfB2Mt2ic+n3JcL4vrKXxBCmB0iNh+5BYiXdJNWed/Q==",
+		{"ID": "guard-2", "Key": "CAISIQJ16EiwvGko4SaBEEUFyMdNZp1vKsTLgIXCY6fRa3/Obg==", "Status": "retiring", "NotAfter": "2030-01-01T00:00:00Z"}
+	]`), &keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Status != ServiceKeyActive || keys[1].Status != ServiceKeyRetiring || keys[1].NotAfter == nil {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+	if err := keys.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	id, err := keys[0].KeyID()
+	if err != nil || id == "" {
+		t.Fatalf("expected a derived key ID, got %q: %v", id, err)
+	}
+
+	out, err := json.Marshal(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `["CAISIQPAfB2Mt2ic+n3JcL4vrKXxBCmB0iNh+5BYiXdJNWed/Q==",` +
+		`{"ID":"guard-2","Key":"CAISIQJ16EiwvGko4SaBEEUFyMdNZp1vKsTLgIXCY6fRa3/Obg==","Status":"retiring","NotAfter":"2030-01-01T00:00:00Z"}]`
+	if string(out) != expected {
+		t.Fatalf("expected plain keys to be written as bare strings, got %s", out)
+	}
+}
+
+func TestServiceKeysValidate(t *testing.T) {
+	_, key := testServiceKey(t, "a")
+	now := time.Now()
+	invalid := []ServiceKeys{
+		{{Key: "not base64"}},
+		{{Key: key.Key, Status: "expired"}},
+		{{Key: key.Key, NotBefore: &now, NotAfter: &now}},
+		{key, key},
+	}
+	for i, keys := range invalid {
+		if keys.Validate() == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
+
+func TestServiceKeysVerify(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	cutover := now.Add(24 * time.Hour)
+
+	oldSK, oldKey := testServiceKey(t, "old")
+	newSK, newKey := testServiceKey(t, "new")
+	_, revokedKey := testServiceKey(t, "revoked")
+	oldKey.Status = ServiceKeyRetiring
+	oldKey.NotAfter = &cutover
+	newKey.NotBefore = &now
+	revokedKey.Status = ServiceKeyRevoked
+	keys := ServiceKeys{oldKey, newKey, revokedKey}
+
+	set, err := keys.VerificationSet(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(set) != 1 || set["old"] == nil {
+		t.Fatalf("expected only the old key before the rotation, got %v", set)
+	}
+
+	data := []byte("contract")
+	oldSig, _ := oldSK.Sign(data)
+	newSig, _ := newSK.Sign(data)
+	if id, err := keys.Verify(now, data, oldSig); err != nil || id != "old" {
+		t.Fatalf("expected the retiring key to verify, got %q: %v", id, err)
+	}
+	if id, err := keys.Verify(now, data, newSig); err != nil || id != "new" {
+		t.Fatalf("expected the new key to verify, got %q: %v", id, err)
+	}
+	if _, err := keys.Verify(cutover, data, oldSig); err == nil {
+		t.Fatal("expected the old key to be rejected after NotAfter")
+	}
+	if _, err := keys.Verify(now, []byte("tampered"), newSig); err == nil {
+		t.Fatal("expected a bad signature to be rejected")
+	}
+}
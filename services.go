@@ -1,15 +1,325 @@
 package config
 
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type Services struct {
-	StatusServerDomain string
-	HubDomain          string
-	EscrowDomain       string
-	GuardDomain        string
-	ExchangeDomain     string
-	SolidityDomain     string
-	FullnodeDomain     string
-	TrongridDomain     string
-
-	EscrowPubKeys []string
-	GuardPubKeys  []string
+	StatusServerDomain ServiceEndpoint
+	HubDomain          ServiceEndpoint
+	EscrowDomain       ServiceEndpoint
+	GuardDomain        ServiceEndpoint
+	ExchangeDomain     ServiceEndpoint
+	SolidityDomain     ServiceEndpoint // gRPC
+	FullnodeDomain     ServiceEndpoint // gRPC
+	TrongridDomain     ServiceEndpoint
+
+	EscrowPubKeys ServiceKeys
+	GuardPubKeys  ServiceKeys
+}
+
+// ServiceKind tells how a service is reached.
+type ServiceKind int
+
+const (
+	// ServiceHTTPS services are reached at an https:// URL.
+	ServiceHTTPS ServiceKind = iota
+	// ServiceGRPC services are reached at a host:port gRPC target.
+	ServiceGRPC
+)
+
+func (k ServiceKind) String() string {
+	if k == ServiceGRPC {
+		return "gRPC"
+	}
+	return "HTTPS"
+}
+
+// ServiceEndpoint configures how an external service is reached. An endpoint
+// with only a URL is stored as a bare URL string, the format used before
+// endpoints were typed, so that older daemons can still read it.
+type ServiceEndpoint struct {
+	// URL is the https:// URL of the service, or its host:port target for
+	// gRPC services.
+	URL string
+
+	// Fallbacks are tried in order when URL cannot be reached. They use
+	// the same format as URL.
+	Fallbacks []string `json:",omitempty"`
+
+	// Timeout bounds a single request. The client default is used when
+	// unset.
+	Timeout Duration `json:",omitempty"`
+
+	// Retry configures how failed requests are retried. Requests are not
+	// retried when unset.
+	Retry *ServiceRetry `json:",omitempty"`
+
+	// TLS overrides the verification of the service certificate.
+	TLS *ServiceTLS `json:",omitempty"`
+
+	// Headers are added to every request.
+	Headers map[string]string `json:",omitempty"`
+}
+
+// ServiceRetry is a retry policy with exponential backoff.
+type ServiceRetry struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry. It doubles on each
+	// further retry, up to MaxBackoff.
+	InitialBackoff Duration
+
+	// MaxBackoff caps the wait between attempts. Unbounded when unset.
+	MaxBackoff Duration `json:",omitempty"`
+}
+
+// ServiceTLS configures how the certificate of a service is verified.
+type ServiceTLS struct {
+	// CAFile is a PEM bundle of the certificate authorities to trust
+	// instead of the system roots.
+	CAFile string `json:",omitempty"`
+
+	// Pins lists the accepted public keys as "sha256/<base64>", the hash
+	// of the DER encoded SubjectPublicKeyInfo. The certificate must match
+	// one of them when set.
+	Pins []string `json:",omitempty"`
+}
+
+const servicePinPrefix = "sha256/"
+
+// String returns the URL of the endpoint.
+func (e ServiceEndpoint) String() string {
+	return e.URL
+}
+
+// UnmarshalJSON accepts either a bare URL or the endpoint object. An empty
+// string is the zero endpoint.
+func (e *ServiceEndpoint) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*e = ServiceEndpoint{URL: value}
+		return nil
+	}
+	var value serviceEndpoint
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*e = ServiceEndpoint(value)
+	return nil
+}
+
+// MarshalJSON writes a bare URL when no other setting is set.
+func (e ServiceEndpoint) MarshalJSON() ([]byte, error) {
+	rest := e
+	rest.URL = ""
+	if rest.unset() {
+		return json.Marshal(e.URL)
+	}
+	return json.Marshal(serviceEndpoint(e))
+}
+
+// serviceEndpoint is ServiceEndpoint without its JSON methods.
+type serviceEndpoint ServiceEndpoint
+
+var _ json.Unmarshaler = (*ServiceEndpoint)(nil)
+var _ json.Marshaler = (*ServiceEndpoint)(nil)
+
+// serviceEndpointFields lists the endpoint fields of Services in order.
+var serviceEndpointFields = []string{
+	"StatusServerDomain", "HubDomain", "EscrowDomain", "GuardDomain",
+	"ExchangeDomain", "SolidityDomain", "FullnodeDomain", "TrongridDomain",
+}
+
+// Endpoints returns the service endpoints keyed by field name.
+func (s *Services) Endpoints() map[string]*ServiceEndpoint {
+	return map[string]*ServiceEndpoint{
+		"StatusServerDomain": &s.StatusServerDomain,
+		"HubDomain":          &s.HubDomain,
+		"EscrowDomain":       &s.EscrowDomain,
+		"GuardDomain":        &s.GuardDomain,
+		"ExchangeDomain":     &s.ExchangeDomain,
+		"SolidityDomain":     &s.SolidityDomain,
+		"FullnodeDomain":     &s.FullnodeDomain,
+		"TrongridDomain":     &s.TrongridDomain,
+	}
+}
+
+// ServiceKindOf returns the kind of the service configured by the named
+// Services field.
+func ServiceKindOf(field string) ServiceKind {
+	switch field {
+	case "SolidityDomain", "FullnodeDomain":
+		return ServiceGRPC
+	default:
+		return ServiceHTTPS
+	}
+}
+
+// Validate checks that every configured endpoint matches the kind of its
+// service, and the service keys. Unset endpoints are skipped.
+func (s *Services) Validate() error {
+	endpoints := s.Endpoints()
+	for _, field := range serviceEndpointFields {
+		e := endpoints[field]
+		if e.unset() {
+			continue
+		}
+		if err := e.Validate(ServiceKindOf(field)); err != nil {
+			return fmt.Errorf("%s: %s", field, err)
+		}
+	}
+	if err := s.EscrowPubKeys.Validate(); err != nil {
+		return fmt.Errorf("EscrowPubKeys: %s", err)
+	}
+	if err := s.GuardPubKeys.Validate(); err != nil {
+		return fmt.Errorf("GuardPubKeys: %s", err)
+	}
+	return nil
+}
+
+// unset reports whether no endpoint and no key is configured.
+func (s *Services) unset() bool {
+	for _, e := range s.Endpoints() {
+		if !e.unset() {
+			return false
+		}
+	}
+	return len(s.EscrowPubKeys) == 0 && len(s.GuardPubKeys) == 0
+}
+
+func (e *ServiceEndpoint) unset() bool {
+	return e.URL == "" && len(e.Fallbacks) == 0 && e.Timeout == 0 &&
+		e.Retry == nil && e.TLS == nil && len(e.Headers) == 0
+}
+
+// Validate checks the endpoint settings for a service of the given kind.
+func (e *ServiceEndpoint) Validate(kind ServiceKind) error {
+	if e.URL == "" {
+		return fmt.Errorf("URL must be set")
+	}
+	for _, addr := range append([]string{e.URL}, e.Fallbacks...) {
+		if err := validateServiceAddr(addr, kind); err != nil {
+			return err
+		}
+	}
+	if e.Timeout < 0 {
+		return fmt.Errorf("Timeout must not be negative")
+	}
+	if r := e.Retry; r != nil {
+		if r.MaxAttempts < 1 {
+			return fmt.Errorf("Retry.MaxAttempts must be at least 1")
+		}
+		if r.InitialBackoff < 0 || r.MaxBackoff < 0 {
+			return fmt.Errorf("Retry backoffs must not be negative")
+		}
+		if r.MaxBackoff != 0 && r.MaxBackoff < r.InitialBackoff {
+			return fmt.Errorf("Retry.MaxBackoff must not be below Retry.InitialBackoff")
+		}
+	}
+	if e.TLS != nil {
+		if e.TLS.CAFile != "" {
+			if err := validateCAFile(e.TLS.CAFile); err != nil {
+				return fmt.Errorf("TLS.CAFile: %s", err)
+			}
+		}
+		for _, pin := range e.TLS.Pins {
+			if err := validateServicePin(pin); err != nil {
+				return fmt.Errorf("TLS.Pins: %s", err)
+			}
+		}
+	}
+	for name := range e.Headers {
+		if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("invalid header name: %q", name)
+		}
+		if http.CanonicalHeaderKey(name) == "Host" {
+			return fmt.Errorf("the Host header cannot be overridden")
+		}
+	}
+	return nil
+}
+
+// Backoff returns the wait before the given retry, starting at 1.
+func (r *ServiceRetry) Backoff(retry int) time.Duration {
+	backoff := time.Duration(r.InitialBackoff)
+	for i := 1; i < retry; i++ {
+		backoff *= 2
+		if r.MaxBackoff != 0 && backoff >= time.Duration(r.MaxBackoff) {
+			return time.Duration(r.MaxBackoff)
+		}
+	}
+	return backoff
+}
+
+// validateServiceAddr checks that addr is an https:// URL for HTTPS
+// services, and a host:port target without a scheme for gRPC services.
+func validateServiceAddr(addr string, kind ServiceKind) error {
+	if kind == ServiceGRPC {
+		if strings.Contains(addr, "://") {
+			return fmt.Errorf("gRPC target %q must be host:port, without a scheme", addr)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid gRPC target %q: %s", addr, err)
+		}
+		if host == "" {
+			return fmt.Errorf("gRPC target %q has no host", addr)
+		}
+		if p, err := strconv.ParseUint(port, 10, 16); err != nil || p == 0 {
+			return fmt.Errorf("gRPC target %q has an invalid port", addr)
+		}
+		return nil
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %s", addr, err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("URL %q must use the https scheme", addr)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL %q has no host", addr)
+	}
+	return nil
+}
+
+// validateCAFile checks that the file holds at least one PEM certificate.
+func validateCAFile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if !x509.NewCertPool().AppendCertsFromPEM(data) {
+		return fmt.Errorf("no PEM certificate found in %s", file)
+	}
+	return nil
+}
+
+func validateServicePin(pin string) error {
+	if !strings.HasPrefix(pin, servicePinPrefix) {
+		return fmt.Errorf("pin %q must start with %q", pin, servicePinPrefix)
+	}
+	digest, err := base64.StdEncoding.DecodeString(pin[len(servicePinPrefix):])
+	if err != nil || len(digest) != sha256.Size {
+		return fmt.Errorf("pin %q is not a base64 sha256 digest", pin)
+	}
+	return nil
 }
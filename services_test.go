@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServiceEndpointDecode(t *testing.T) {
+	var s Services
+	err := json.Unmarshal([]byte(`{
+		"HubDomain": "https://hub.btfs.io",
+		"SolidityDomain": {"URL": "grpc.trongrid.io:50052", "Fallbacks": ["backup.trongrid.io:50052"], "Timeout": "5s"}
+	}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.HubDomain.URL != "https://hub.btfs.io" {
+		t.Fatalf("legacy string not decoded: %+v", s.HubDomain)
+	}
+	expected := ServiceEndpoint{
+		URL:       "grpc.trongrid.io:50052",
+		Fallbacks: []string{"backup.trongrid.io:50052"},
+		Timeout:   Duration(5 * time.Second),
+	}
+	if !reflect.DeepEqual(s.SolidityDomain, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, s.SolidityDomain)
+	}
+
+	out, err := json.Marshal(s.HubDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `"https://hub.btfs.io"` {
+		t.Fatalf("expected a bare URL, got %s", out)
+	}
+	out, err = json.Marshal(s.SolidityDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"URL":"grpc.trongrid.io:50052","Fallbacks":["backup.trongrid.io:50052"],"Timeout":"5s"}` {
+		t.Fatalf("unexpected encoding: %s", out)
+	}
+
+	var e ServiceEndpoint
+	if err := json.Unmarshal([]byte(`""`), &e); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e, ServiceEndpoint{}) {
+		t.Fatalf("expected an empty string to be the zero endpoint, got %+v", e)
+	}
+}
+
+func TestServicesValidate(t *testing.T) {
+	for name, s := range map[string]Services{
+		"mainnet": DefaultServicesConfig(),
+		"testnet": DefaultServicesConfigTestnet(),
+		"dev":     DefaultServicesConfigDev(),
+		"empty":   {},
+	} {
+		if err := s.Validate(); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+
+	invalid := []func(s *Services){
+		func(s *Services) { s.HubDomain.URL = "http://hub.btfs.io" },
+		func(s *Services) { s.HubDomain.URL = "hub.btfs.io:443" },
+		func(s *Services) { s.HubDomain.Fallbacks = []string{"https://"} },
+		func(s *Services) { s.SolidityDomain.URL = "https://grpc.trongrid.io:50052" },
+		func(s *Services) { s.FullnodeDomain.URL = "grpc.trongrid.io" },
+		func(s *Services) { s.FullnodeDomain.URL = "grpc.trongrid.io:0" },
+		func(s *Services) { s.GuardDomain = ServiceEndpoint{Timeout: Duration(time.Second)} },
+		func(s *Services) { s.EscrowDomain.Timeout = Duration(-time.Second) },
+		func(s *Services) { s.EscrowDomain.Retry = &ServiceRetry{} },
+		func(s *Services) {
+			s.EscrowDomain.Retry = &ServiceRetry{MaxAttempts: 3, InitialBackoff: Duration(time.Second), MaxBackoff: Duration(time.Millisecond)}
+		},
+		func(s *Services) { s.EscrowDomain.TLS = &ServiceTLS{CAFile: "/nonexistent/ca.pem"} },
+		func(s *Services) { s.EscrowDomain.TLS = &ServiceTLS{Pins: []string{"sha256/abc"}} },
+		func(s *Services) { s.EscrowDomain.Headers = map[string]string{"Bad Header": "1"} },
+		func(s *Services) { s.EscrowDomain.Headers = map[string]string{"host": "example.com"} },
+	}
+	for i, mutate := range invalid {
+		s := DefaultServicesConfig()
+		mutate(&s)
+		if s.Validate() == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+
+	s := DefaultServicesConfig()
+	s.EscrowDomain.Retry = &ServiceRetry{MaxAttempts: 3, InitialBackoff: Duration(time.Second)}
+	s.EscrowDomain.TLS = &ServiceTLS{Pins: []string{"sha256/" + strings.Repeat("A", 43) + "="}}
+	s.EscrowDomain.Headers = map[string]string{"X-Api-Key": "secret"}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServiceTLSCAFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "service-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestCert(t, dir, "escrow.example.com", time.Now().Add(time.Hour))
+	s := DefaultServicesConfig()
+	s.EscrowDomain.TLS = &ServiceTLS{CAFile: certFile}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	s.EscrowDomain.TLS.CAFile = keyFile
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected a file without certificates to be rejected")
+	}
+}
+
+func TestServiceRetryBackoff(t *testing.T) {
+	r := ServiceRetry{MaxAttempts: 5, InitialBackoff: Duration(time.Second), MaxBackoff: Duration(3 * time.Second)}
+	for retry, expected := range []time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second, 4: 3 * time.Second} {
+		if retry == 0 {
+			continue
+		}
+		if got := r.Backoff(retry); got != expected {
+			t.Errorf("retry %d: expected %s, got %s", retry, expected, got)
+		}
+	}
+}
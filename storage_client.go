@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	hubpb "github.com/tron-us/go-btfs-common/protos/hub"
+)
+
+// HostSelection is the strategy a storage client uses to pick hosts.
+type HostSelection string
+
+const (
+	// HostSelectionScore prefers hosts with the best hub score.
+	HostSelectionScore HostSelection = "score"
+	// HostSelectionGeo prefers hosts close to the client.
+	HostSelectionGeo HostSelection = "geo"
+	// HostSelectionLatency prefers hosts with the lowest latency.
+	HostSelectionLatency HostSelection = "latency"
+	// HostSelectionCustom only uses the hosts in AllowedHosts.
+	HostSelectionCustom HostSelection = "custom"
+)
+
+// hostSelectionModes maps each strategy to the mainnet hub mode hosts are
+// synced with. The "custom" strategy still syncs by score to learn the
+// addresses of the allowed hosts.
+var hostSelectionModes = map[HostSelection]HostsSyncMode{
+	HostSelectionScore:   HostsSyncMode(hubpb.HostsReq_SCORE),
+	HostSelectionGeo:     HostsSyncMode(hubpb.HostsReq_GEO),
+	HostSelectionLatency: HostsSyncMode(hubpb.HostsReq_SPEED),
+	HostSelectionCustom:  HostsSyncMode(hubpb.HostsReq_SCORE),
+}
+
+// MaxStorageShards is the largest total of data and parity shards supported
+// by the erasure coding.
+const MaxStorageShards = 256
+
+// StorageClient configures how a renter stores files on the network. Prices
+// and budgets are in µBTT.
+type StorageClient struct {
+	// Budget caps the total spent on storage contracts. Zero means no cap.
+	Budget int64
+
+	// DefaultStorageLength is the contract length used when an upload
+	// does not specify one.
+	DefaultStorageLength Duration
+
+	// DataShards and ParityShards set the erasure coding of uploads: files
+	// are split into DataShards shards and ParityShards redundant shards
+	// are added, each stored on a different host.
+	DataShards   int
+	ParityShards int
+
+	// HostSelection is the strategy used to pick hosts.
+	HostSelection HostSelection
+
+	// AllowedHosts lists the host peer IDs used by the "custom" strategy;
+	// see AcceptsHost.
+	AllowedHosts []string `json:",omitempty"`
+
+	// MaxPricePerGiBMonth is the highest price accepted for storing one
+	// GiB for 30 days. Zero means no limit.
+	MaxPricePerGiBMonth int64
+}
+
+// HubMode returns the hub mode hosts are synced with for the strategy. The
+// testnet hub only serves DefaultHostsSyncModeDev, whatever the strategy.
+// The storage client profiles set Experiments.HostsSyncMode from it.
+func (s *StorageClient) HubMode(testnet bool) HostsSyncMode {
+	if testnet {
+		return HostsSyncMode(DefaultHostsSyncModeDev)
+	}
+	if mode, ok := hostSelectionModes[s.HostSelection]; ok {
+		return mode
+	}
+	return HostsSyncMode(DefaultHostsSyncMode)
+}
+
+// AcceptsHost reports whether a host peer ID may be picked: with the
+// "custom" strategy only the AllowedHosts are, otherwise any host is.
+func (s *StorageClient) AcceptsHost(host string) bool {
+	if s.HostSelection != HostSelectionCustom {
+		return true
+	}
+	for _, id := range s.AllowedHosts {
+		if id == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the numeric ranges and the host selection strategy.
+func (s *StorageClient) Validate() error {
+	if s.Budget < 0 {
+		return fmt.Errorf("Budget must not be negative")
+	}
+	if s.MaxPricePerGiBMonth < 0 {
+		return fmt.Errorf("MaxPricePerGiBMonth must not be negative")
+	}
+	length := time.Duration(s.DefaultStorageLength)
+	if length < MinStorageContractDuration || length > MaxStorageContractDuration {
+		return fmt.Errorf("DefaultStorageLength must be between %s and %s",
+			MinStorageContractDuration, MaxStorageContractDuration)
+	}
+	if s.DataShards < 1 {
+		return fmt.Errorf("DataShards must be at least 1")
+	}
+	if s.ParityShards < 0 {
+		return fmt.Errorf("ParityShards must not be negative")
+	}
+	if s.DataShards+s.ParityShards > MaxStorageShards {
+		return fmt.Errorf("DataShards and ParityShards must not exceed %d shards in total", MaxStorageShards)
+	}
+	if _, ok := hostSelectionModes[s.HostSelection]; !ok {
+		return fmt.Errorf("unknown HostSelection %q", s.HostSelection)
+	}
+	if _, err := decodePeerIDs(s.AllowedHosts, nil); err != nil {
+		return fmt.Errorf("AllowedHosts: %s", err)
+	}
+	if s.HostSelection == HostSelectionCustom && len(s.AllowedHosts) == 0 {
+		return fmt.Errorf("AllowedHosts must not be empty with the %q strategy", HostSelectionCustom)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	hubpb "github.com/tron-us/go-btfs-common/protos/hub"
+)
+
+func TestStorageClientValidate(t *testing.T) {
+	s := DefaultStorageClientConfig()
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []func(s *StorageClient){
+		func(s *StorageClient) { s.Budget = -1 },
+		func(s *StorageClient) { s.MaxPricePerGiBMonth = -1 },
+		func(s *StorageClient) { s.DefaultStorageLength = Duration(time.Hour) },
+		func(s *StorageClient) { s.DataShards = 0 },
+		func(s *StorageClient) { s.ParityShards = -1 },
+		func(s *StorageClient) { s.DataShards, s.ParityShards = 200, 100 },
+		func(s *StorageClient) { s.HostSelection = "cheapest" },
+		func(s *StorageClient) { s.HostSelection = HostSelectionCustom },
+		func(s *StorageClient) { s.AllowedHosts = []string{"not-a-peer"} },
+	} {
+		s := DefaultStorageClientConfig()
+		change(&s)
+		if err := s.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", s)
+		}
+	}
+
+	s.HostSelection = HostSelectionCustom
+	s.AllowedHosts = []string{testPeerA, testPeerB}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if s.HubMode(false) != HostsSyncMode(hubpb.HostsReq_SCORE) {
+		t.Fatalf("unexpected hub mode: %s", s.HubMode(false))
+	}
+	otherHost := "QmNnooDu7bfjPFoTZYxMNLWUQJyrVwtbZg5gBMjTezGAJN"
+	if !s.AcceptsHost(testPeerA) || s.AcceptsHost(otherHost) {
+		t.Fatal("expected only the allowed hosts with the custom strategy")
+	}
+	s.HostSelection = HostSelectionLatency
+	if s.HubMode(false) != HostsSyncMode(hubpb.HostsReq_SPEED) {
+		t.Fatalf("unexpected hub mode: %s", s.HubMode(false))
+	}
+	if s.HubMode(true) != HostsSyncMode(hubpb.HostsReq_TESTNET) {
+		t.Fatalf("unexpected testnet hub mode: %s", s.HubMode(true))
+	}
+	if !s.AcceptsHost(otherHost) {
+		t.Fatal("expected any host without the custom strategy")
+	}
+}
+
+func TestStorageClientProfiles(t *testing.T) {
+	for _, name := range []string{"storage-client", "storage-client-dev", "storage-client-testnet"} {
+		c := new(Config)
+		if err := Profiles[name].Transform(c); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.StorageClient.Validate(); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if c.Experimental.HostsSyncMode != c.StorageClient.HubMode(name != "storage-client") {
+			t.Fatalf("%s: unexpected hosts sync mode: %s", name, c.Experimental.HostsSyncMode)
+		}
+	}
+
+	// the mainnet hub mode follows the strategy
+	c := new(Config)
+	c.StorageClient = DefaultStorageClientConfig()
+	c.StorageClient.HostSelection = HostSelectionGeo
+	if err := Profiles["storage-client"].Transform(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Experimental.HostsSyncMode != HostsSyncMode(hubpb.HostsReq_GEO) {
+		t.Fatalf("unexpected hosts sync mode: %s", c.Experimental.HostsSyncMode)
+	}
+
+	// explicit settings are kept
+	c = new(Config)
+	c.StorageClient = DefaultStorageClientConfig()
+	c.StorageClient.Budget = 1000
+	if err := Profiles["storage-client"].Transform(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.StorageClient.Budget != 1000 {
+		t.Fatal("profile overwrote the StorageClient settings")
+	}
+}
@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits enforced on the StorageHost settings.
+const (
+	// MinStorageContractDuration is the shortest contract a host may accept.
+	MinStorageContractDuration = 24 * time.Hour
+	// MaxStorageContractDuration is the longest contract a host may accept.
+	MaxStorageContractDuration = 5 * 365 * 24 * time.Hour
+	// MaxStorageShardSize is the largest shard size a host may accept.
+	MaxStorageShardSize = 32 << 30
+)
+
+// StorageHost configures the economics and contract limits of a storage
+// host. Prices and collateral are in µBTT.
+type StorageHost struct {
+	// PricePerGiBMonth is the asking price for storing one GiB for 30 days.
+	PricePerGiBMonth int64
+
+	// CollateralPerGiB is the collateral the host locks per GiB stored.
+	CollateralPerGiB int64
+
+	// MinContractDuration and MaxContractDuration bound the length of
+	// accepted contracts.
+	MinContractDuration Duration
+	MaxContractDuration Duration
+
+	// MaxShardSize is the largest shard accepted, such as "1GB".
+	MaxShardSize string
+
+	// Capacity limits the space offered to contracts, such as "2TB". Empty
+	// offers the advertised capacity of the datastore; see
+	// Config.StorageHostCapacity.
+	Capacity string `json:",omitempty"`
+
+	// AllowedRenters, when not empty, lists the only renter peer IDs whose
+	// contracts are accepted.
+	AllowedRenters []string `json:",omitempty"`
+
+	// DeniedRenters lists renter peer IDs whose contracts are refused.
+	DeniedRenters []string `json:",omitempty"`
+
+	// ContractManager limits how many contracts the host tracks.
+	ContractManager *ContractManager `json:",omitempty"`
+}
+
+// Validate checks the numeric ranges and renter lists.
+func (h *StorageHost) Validate() error {
+	if h.PricePerGiBMonth < 0 {
+		return fmt.Errorf("PricePerGiBMonth must not be negative")
+	}
+	if h.CollateralPerGiB < 0 {
+		return fmt.Errorf("CollateralPerGiB must not be negative")
+	}
+	min, max := time.Duration(h.MinContractDuration), time.Duration(h.MaxContractDuration)
+	if min < MinStorageContractDuration {
+		return fmt.Errorf("MinContractDuration must be at least %s", MinStorageContractDuration)
+	}
+	if max > MaxStorageContractDuration {
+		return fmt.Errorf("MaxContractDuration must be at most %s", MaxStorageContractDuration)
+	}
+	if min > max {
+		return fmt.Errorf("MinContractDuration must not exceed MaxContractDuration")
+	}
+	shard, err := parseByteSize(h.MaxShardSize)
+	if err != nil {
+		return fmt.Errorf("MaxShardSize: %s", err)
+	}
+	if shard == 0 || shard > MaxStorageShardSize {
+		return fmt.Errorf("MaxShardSize must be between 1B and 32GiB")
+	}
+	if h.Capacity != "" {
+		capacity, err := parseByteSize(h.Capacity)
+		if err != nil {
+			return fmt.Errorf("Capacity: %s", err)
+		}
+		if capacity < shard {
+			return fmt.Errorf("Capacity must not be below MaxShardSize")
+		}
+	}
+	allowed, err := decodePeerIDs(h.AllowedRenters, nil)
+	if err != nil {
+		return fmt.Errorf("AllowedRenters: %s", err)
+	}
+	denied, err := decodePeerIDs(h.DeniedRenters, nil)
+	if err != nil {
+		return fmt.Errorf("DeniedRenters: %s", err)
+	}
+	for id := range denied {
+		if _, ok := allowed[id]; ok {
+			return fmt.Errorf("renter %s is both allowed and denied", id)
+		}
+	}
+	if h.ContractManager != nil {
+		if err := h.ContractManager.Validate(); err != nil {
+			return fmt.Errorf("ContractManager: %s", err)
+		}
+	}
+	return nil
+}
+
+// StorageHostCapacity returns the space offered to contracts: the advertised
+// capacity of the datastore, limited to StorageHost.Capacity. freeSpace is
+// passed to Datastore.AdvertisedCapacity.
+func (c *Config) StorageHostCapacity(freeSpace func(path string) (uint64, error)) (uint64, error) {
+	total, _, err := c.Datastore.AdvertisedCapacity(freeSpace)
+	if err != nil {
+		return 0, fmt.Errorf("Datastore: %s", err)
+	}
+	if c.StorageHost.Capacity != "" {
+		limit, err := parseByteSize(c.StorageHost.Capacity)
+		if err != nil {
+			return 0, fmt.Errorf("StorageHost: Capacity: %s", err)
+		}
+		if limit < total {
+			total = limit
+		}
+	}
+	return total, nil
+}
+
+// validateCapacity checks that StorageHost.Capacity fits in the datastore
+// when its size is known without reading the disks: StorageMax without
+// volumes, or the sum of the Caps when every volume has one.
+func (c *Config) validateCapacity() error {
+	if c.StorageHost.Capacity == "" {
+		return nil
+	}
+	capacity, err := parseByteSize(c.StorageHost.Capacity)
+	if err != nil {
+		return fmt.Errorf("StorageHost: Capacity: %s", err)
+	}
+	if len(c.Datastore.Volumes) > 0 {
+		if limit, ok := c.Datastore.capLimit(); ok && capacity > limit {
+			return fmt.Errorf("StorageHost: Capacity must not exceed the sum of the volume Caps")
+		}
+		return nil
+	}
+	if c.Datastore.StorageMax == "" {
+		return nil
+	}
+	if limit, err := parseByteSize(c.Datastore.StorageMax); err == nil && capacity > limit {
+		return fmt.Errorf("StorageHost: Capacity must not exceed Datastore.StorageMax")
+	}
+	return nil
+}
+
+// AcceptsRenter reports whether contracts from a renter peer ID are accepted.
+func (h *StorageHost) AcceptsRenter(renter string) bool {
+	for _, id := range h.DeniedRenters {
+		if id == renter {
+			return false
+		}
+	}
+	if len(h.AllowedRenters) == 0 {
+		return true
+	}
+	for _, id := range h.AllowedRenters {
+		if id == renter {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the watermarks are positive and ordered.
+func (m *ContractManager) Validate() error {
+	if m.LowWater <= 0 {
+		return fmt.Errorf("LowWater must be positive")
+	}
+	if m.HighWater < m.LowWater {
+		return fmt.Errorf("HighWater must not be below LowWater")
+	}
+	if m.Threshold < 0 {
+		return fmt.Errorf("Threshold must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStorageHostValidate(t *testing.T) {
+	h := DefaultStorageHostConfig()
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, change := range []func(h *StorageHost){
+		func(h *StorageHost) { h.PricePerGiBMonth = -1 },
+		func(h *StorageHost) { h.CollateralPerGiB = -1 },
+		func(h *StorageHost) { h.MinContractDuration = Duration(time.Hour) },
+		func(h *StorageHost) { h.MaxContractDuration = Duration(10 * 365 * 24 * time.Hour) },
+		func(h *StorageHost) {
+			h.MinContractDuration = Duration(365 * 24 * time.Hour)
+			h.MaxContractDuration = Duration(48 * time.Hour)
+		},
+		func(h *StorageHost) { h.MaxShardSize = "64GiB" },
+		func(h *StorageHost) { h.MaxShardSize = "0" },
+		func(h *StorageHost) { h.AllowedRenters = []string{"not-a-peer"} },
+		func(h *StorageHost) { h.AllowedRenters = []string{testPeerA}; h.DeniedRenters = []string{testPeerA} },
+		func(h *StorageHost) { h.ContractManager = &ContractManager{LowWater: 300, HighWater: 100} },
+		func(h *StorageHost) { h.Capacity = "plenty" },
+		func(h *StorageHost) { h.Capacity = "100MB" },
+	} {
+		h := DefaultStorageHostConfig()
+		change(&h)
+		if err := h.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", h)
+		}
+	}
+}
+
+func TestStorageHostAcceptsRenter(t *testing.T) {
+	h := StorageHost{DeniedRenters: []string{testPeerB}}
+	if !h.AcceptsRenter(testPeerA) || h.AcceptsRenter(testPeerB) {
+		t.Fatal("unexpected result with a deny list")
+	}
+	h = StorageHost{AllowedRenters: []string{testPeerA}}
+	if !h.AcceptsRenter(testPeerA) || h.AcceptsRenter(testPeerB) {
+		t.Fatal("unexpected result with an allow list")
+	}
+}
+
+func TestStorageHostCapacity(t *testing.T) {
+	cfg := &Config{
+		Datastore:   Datastore{StorageMax: "10GB"},
+		StorageHost: StorageHost{Capacity: "4GB"},
+	}
+	if err := cfg.validateCapacity(); err != nil {
+		t.Fatal(err)
+	}
+	if c, err := cfg.StorageHostCapacity(nil); err != nil || c != 4e9 {
+		t.Fatalf("expected Capacity to limit StorageMax, got %d, %v", c, err)
+	}
+	cfg.StorageHost.Capacity = "20GB"
+	if err := cfg.validateCapacity(); err == nil {
+		t.Fatal("expected Capacity above StorageMax to be invalid")
+	}
+
+	cfg.Datastore.Volumes = testVolumes()
+	if err := cfg.validateCapacity(); err != nil {
+		t.Fatal(err)
+	}
+	cfg.StorageHost.Capacity = "5TB"
+	if err := cfg.validateCapacity(); err != nil {
+		t.Fatalf("expected a volume without Cap to leave Capacity unchecked: %s", err)
+	}
+	free := func(string) (uint64, error) { return 2e12, nil }
+	if c, err := cfg.StorageHostCapacity(free); err != nil || c != 3.9e12 {
+		t.Fatalf("expected the advertised capacity of all volumes, got %d, %v", c, err)
+	}
+	cfg.Datastore.Volumes[1].Cap = "500GB"
+	if err := cfg.validateCapacity(); err == nil {
+		t.Fatal("expected Capacity above the sum of the volume Caps to be invalid")
+	}
+}
@@ -1,10 +1,21 @@
 package config
 
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	ma "github.com/multiformats/go-multiaddr"
+)
+
 type SwarmConfig struct {
 	// AddrFilters specifies a set libp2p addresses that we should never
 	// dial or receive connections from.
 	AddrFilters []string
 
+	// ConnectionGater configures allow and deny rules for connections, on
+	// top of AddrFilters.
+	ConnectionGater GaterConfig
+
 	// DisableBandwidthMetrics disables recording of bandwidth metrics for a
 	// slight reduction in memory usage. You probably don't need to set this
 	// flag.
@@ -13,6 +24,9 @@ type SwarmConfig struct {
 	// DisableNatPortMap turns off NAT port mapping (UPnP, etc.).
 	DisableNatPortMap bool
 
+	// NAT configures NAT traversal.
+	NAT NATConfig
+
 	// DisableRelay explicitly disables the relay transport.
 	//
 	// Deprecated: This flag is deprecated and is overridden by
@@ -21,7 +35,10 @@ type SwarmConfig struct {
 
 	// EnableRelayHop makes this node act as a public relay, relaying
 	// traffic between other nodes.
-	EnableRelayHop bool
+	//
+	// Deprecated: This flag is deprecated and is migrated to
+	// `RelayService.Enabled`.
+	EnableRelayHop bool `json:",omitempty"`
 
 	SwarmKey                string
 
@@ -31,7 +48,17 @@ type SwarmConfig struct {
 	// will advertise itself as a public relay. Otherwise it will find and use
 	// advertised public relays when it determines that it's not reachable
 	// from the public internet.
-	EnableAutoRelay bool
+	//
+	// Deprecated: This flag is deprecated and is migrated to
+	// `RelayClient.Enabled`.
+	EnableAutoRelay bool `json:",omitempty"`
+
+	// RelayClient controls the client side of circuit relay v2.
+	RelayClient RelayClient
+
+	// RelayService configures this node to act as a circuit relay v2
+	// service for other peers.
+	RelayService RelayService
 
 	// Transports contains flags to enable/disable libp2p transports.
 	Transports Transports
@@ -44,11 +71,36 @@ type Transports struct {
 	// Network specifies the base transports we'll use for dialing. To
 	// listen on a transport, add the transport to your Addresses.Swarm.
 	Network struct {
-		// All default to on.
+		// All default to on, except QUICv1, WebTransport and WebRTCDirect.
 		QUIC      Flag `json:",omitempty"`
 		TCP       Flag `json:",omitempty"`
 		Websocket Flag `json:",omitempty"`
 		Relay     Flag `json:",omitempty"`
+
+		// QUICv1 enables the RFC 9000 QUIC transport (`/quic-v1`). The
+		// QUIC flag above only covers the draft-29 version (`/quic`).
+		// Defaults to off. Its listen addresses are only emitted, and
+		// `/quic` addresses only rewritten, once the linked go-multiaddr
+		// can parse `/quic-v1`.
+		QUICv1 Flag `json:",omitempty"`
+
+		// WebTransport enables the WebTransport transport
+		// (`/quic-v1/webtransport`). It shares the UDP port with QUICv1.
+		// Defaults to off, like QUICv1.
+		WebTransport Flag `json:",omitempty"`
+
+		// WebRTCDirect enables the browser-to-server WebRTC transport
+		// (`/webrtc-direct`). Defaults to off.
+		WebRTCDirect Flag `json:",omitempty"`
+	}
+
+	// Websocket configures the websocket transport.
+	Websocket struct {
+		// TLSCertFile and TLSKeyFile are the paths to a PEM encoded
+		// certificate and private key. When both are set, the node can
+		// listen on secure websocket (`/wss`) addresses.
+		TLSCertFile string `json:",omitempty"`
+		TLSKeyFile  string `json:",omitempty"`
 	}
 
 	// Security specifies the transports used to encrypt insecure network
@@ -72,6 +124,106 @@ type Transports struct {
 	}
 }
 
+// RelayClient configures how the node makes use of relays run by other peers.
+type RelayClient struct {
+	// Enabled makes the node look for relays and use them when it is not
+	// reachable from the public internet. Defaults to on.
+	Enabled Flag `json:",omitempty"`
+
+	// StaticRelays is a list of trusted relay multiaddrs, each including
+	// a /p2p/ component. When set, the node only uses these relays instead
+	// of discovering public ones.
+	StaticRelays []string `json:",omitempty"`
+}
+
+// RelayService configures the resources this node offers when relaying
+// traffic for other peers.
+//
+// Zero values fall back to the libp2p defaults listed on each field.
+type RelayService struct {
+	// Enabled makes this node act as a relay for other peers. Defaults to
+	// off.
+	Enabled Flag `json:",omitempty"`
+
+	// ConnectionDurationLimit is the time limit before a relayed
+	// connection is reset. Defaults to 2 minutes.
+	ConnectionDurationLimit Duration `json:",omitempty"`
+
+	// ConnectionDataLimit is the number of bytes relayed in each direction
+	// before a relayed connection is reset. Defaults to 128KiB.
+	ConnectionDataLimit int64 `json:",omitempty"`
+
+	// ReservationTTL is the duration of a new or refreshed reservation.
+	// Defaults to 1 hour.
+	ReservationTTL Duration `json:",omitempty"`
+
+	// MaxReservations is the maximum number of active relay slots.
+	// Defaults to 128.
+	MaxReservations int `json:",omitempty"`
+
+	// MaxCircuits is the maximum number of open relayed connections for
+	// each peer. Defaults to 16.
+	MaxCircuits int `json:",omitempty"`
+
+	// BufferSize is the size of the relayed connection buffers in bytes.
+	// Defaults to 2048.
+	BufferSize int `json:",omitempty"`
+
+	// MaxReservationsPerPeer, MaxReservationsPerIP and
+	// MaxReservationsPerASN limit the number of reservations held by a
+	// single peer, IP address and autonomous system. They default to 4, 8
+	// and 32.
+	MaxReservationsPerPeer int `json:",omitempty"`
+	MaxReservationsPerIP   int `json:",omitempty"`
+	MaxReservationsPerASN  int `json:",omitempty"`
+}
+
+// Validate checks the swarm settings for invalid values and contradictory
+// combinations of NAT and relay options.
+func (s *SwarmConfig) Validate() error {
+	if s.DisableNatPortMap && s.NAT.PortMapping != NATPortMappingAuto && s.NAT.PortMapping != NATPortMappingNone {
+		return fmt.Errorf("NAT.PortMapping is set but DisableNatPortMap disables port mapping")
+	}
+
+	relayTransport := s.Transports.Network.Relay.WithDefault(!s.DisableRelay)
+
+	if s.RelayService.Enabled == True && !relayTransport {
+		return fmt.Errorf("RelayService.Enabled requires the relay transport, which is disabled")
+	}
+	if s.RelayService.Enabled == False && s.EnableRelayHop {
+		return fmt.Errorf("RelayService.Enabled is false but the deprecated EnableRelayHop is set")
+	}
+	if s.RelayClient.Enabled == True && !relayTransport {
+		return fmt.Errorf("RelayClient.Enabled requires the relay transport, which is disabled")
+	}
+	if s.RelayClient.Enabled == False && s.EnableAutoRelay {
+		return fmt.Errorf("RelayClient.Enabled is false but the deprecated EnableAutoRelay is set")
+	}
+	if len(s.RelayClient.StaticRelays) > 0 && !s.RelayClient.Enabled.WithDefault(DefaultEnableAutoRelay) {
+		return fmt.Errorf("RelayClient.StaticRelays is set but RelayClient is disabled")
+	}
+	for _, addr := range s.RelayClient.StaticRelays {
+		maddr, err := ma.NewMultiaddr(addr)
+		if err != nil {
+			return fmt.Errorf("invalid static relay %q: %s", addr, err)
+		}
+		if _, err := peer.AddrInfoFromP2pAddr(maddr); err != nil {
+			return fmt.Errorf("invalid static relay %q: %s", addr, err)
+		}
+	}
+
+	rs := s.RelayService
+	if rs.ConnectionDurationLimit < 0 || rs.ReservationTTL < 0 {
+		return fmt.Errorf("RelayService durations must not be negative")
+	}
+	if rs.ConnectionDataLimit < 0 || rs.MaxReservations < 0 || rs.MaxCircuits < 0 ||
+		rs.BufferSize < 0 || rs.MaxReservationsPerPeer < 0 ||
+		rs.MaxReservationsPerIP < 0 || rs.MaxReservationsPerASN < 0 {
+		return fmt.Errorf("RelayService limits must not be negative")
+	}
+	return nil
+}
+
 // ConnMgr defines configuration options for the libp2p connection manager
 type ConnMgr struct {
 	Type        string
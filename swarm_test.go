@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestSwarmValidateRelay(t *testing.T) {
+	const relay = "/ip4/1.2.3.4/tcp/4001/p2p/QmaCpDMGvV2BGHeYERUEnRQAwe3N8SzbUtfsmvsqQLuvuJ"
+
+	var s SwarmConfig
+	s.RelayClient.StaticRelays = []string{relay}
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.RelayClient.Enabled = False
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected static relays with a disabled client to be rejected")
+	}
+
+	s.RelayClient.Enabled = Default
+	s.RelayClient.StaticRelays = []string{"/ip4/1.2.3.4/tcp/4001"}
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected static relay without a peer ID to be rejected")
+	}
+
+	s = SwarmConfig{}
+	s.Transports.Network.Relay = False
+	s.RelayService.Enabled = True
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected relay service without relay transport to be rejected")
+	}
+
+	s = SwarmConfig{}
+	s.RelayService.MaxCircuits = -1
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected negative limit to be rejected")
+	}
+}
@@ -232,3 +232,51 @@ func (d Duration) String() string {
 
 var _ encoding.TextUnmarshaler = (*Duration)(nil)
 var _ encoding.TextMarshaler = (*Duration)(nil)
+
+// TimeOfDayRange is a range of local time of day, such as 22:00 to 06:00.
+// Ranges ending before they start span midnight.
+type TimeOfDayRange struct {
+	Start string
+	End   string
+}
+
+// Contains reports whether t falls in the range. Invalid ranges contain
+// nothing.
+func (r TimeOfDayRange) Contains(t time.Time) bool {
+	start, err1 := parseTimeOfDay(r.Start)
+	end, err2 := parseTimeOfDay(r.End)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// Validate checks that both ends are HH:MM times and that the range is not
+// empty.
+func (r TimeOfDayRange) Validate() error {
+	start, err := parseTimeOfDay(r.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseTimeOfDay(r.End)
+	if err != nil {
+		return err
+	}
+	if start == end {
+		return fmt.Errorf("empty range %s-%s", r.Start, r.End)
+	}
+	return nil
+}
+
+// parseTimeOfDay parses "HH:MM" into minutes after midnight.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
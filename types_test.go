@@ -218,3 +218,29 @@ func TestPriority(t *testing.T) {
 		}
 	}
 }
+
+func TestTimeOfDayRange(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2020, 1, 1, h, m, 0, 0, time.UTC) }
+	night := TimeOfDayRange{Start: "22:00", End: "06:00"}
+	for _, tc := range []struct {
+		t        time.Time
+		expected bool
+	}{
+		{at(23, 0), true},
+		{at(5, 59), true},
+		{at(6, 0), false},
+		{at(12, 0), false},
+	} {
+		if night.Contains(tc.t) != tc.expected {
+			t.Errorf("Contains(%s) = %t", tc.t.Format("15:04"), !tc.expected)
+		}
+	}
+	if err := night.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []TimeOfDayRange{{"25:00", "06:00"}, {"22:00", "6"}, {"10:00", "10:00"}} {
+		if r.Validate() == nil {
+			t.Errorf("expected %v to be invalid", r)
+		}
+	}
+}
@@ -7,8 +7,10 @@ type UI struct {
 }
 
 type HostUI struct {
-	Initialized     bool
-	ContractManager *ContractManager
+	Initialized bool
+
+	// deprecated, use StorageHost.ContractManager
+	ContractManager *ContractManager `json:",omitempty"`
 }
 
 type ContractManager struct {
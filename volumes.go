@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Volume is a disk a storage host offers space on.
+type Volume struct {
+	// Path is the absolute path blocks are stored under.
+	Path string
+
+	// Cap limits the space used on the volume, such as "4TB". Empty means
+	// the free space of the disk.
+	Cap string `json:",omitempty"`
+
+	// Reserved is the free space, such as "50GB", always left on the disk.
+	Reserved string `json:",omitempty"`
+}
+
+// VolumeCapacity reports the capacity a volume contributes.
+type VolumeCapacity struct {
+	Path       string
+	Free       uint64
+	Advertised uint64
+}
+
+// AdvertisedCapacity returns the capacity the host can offer: the sum over
+// the volumes of their free space less Reserved, each limited to its Cap.
+// freeSpace reports the free space under a path; nil reads it from the
+// filesystem. Without volumes, the capacity is StorageMax.
+func (d *Datastore) AdvertisedCapacity(freeSpace func(path string) (uint64, error)) (uint64, []VolumeCapacity, error) {
+	if len(d.Volumes) == 0 {
+		max, err := parseByteSize(d.StorageMax)
+		if err != nil {
+			return 0, nil, fmt.Errorf("StorageMax: %s", err)
+		}
+		return max, nil, nil
+	}
+	if freeSpace == nil {
+		freeSpace = diskFree
+	}
+
+	var total uint64
+	caps := make([]VolumeCapacity, 0, len(d.Volumes))
+	for _, v := range d.Volumes {
+		free, err := freeSpace(v.Path)
+		if err != nil {
+			return 0, nil, fmt.Errorf("volume %s: %s", v.Path, err)
+		}
+		c := VolumeCapacity{Path: v.Path, Free: free}
+		reserved, limit, err := v.sizes()
+		if err != nil {
+			return 0, nil, err
+		}
+		if free > reserved {
+			c.Advertised = free - reserved
+		}
+		if limit > 0 && c.Advertised > limit {
+			c.Advertised = limit
+		}
+		total += c.Advertised
+		caps = append(caps, c)
+	}
+	return total, caps, nil
+}
+
+// capLimit returns the sum of the Caps of the volumes, and false when a
+// volume has no Cap and the total depends on the free space of its disk.
+func (d *Datastore) capLimit() (uint64, bool) {
+	var total uint64
+	for _, v := range d.Volumes {
+		_, limit, err := v.sizes()
+		if err != nil || limit == 0 {
+			return 0, false
+		}
+		total += limit
+	}
+	return total, true
+}
+
+// sizes parses Reserved and Cap; a zero limit means no Cap.
+func (v *Volume) sizes() (reserved, limit uint64, err error) {
+	if v.Reserved != "" {
+		if reserved, err = parseByteSize(v.Reserved); err != nil {
+			return 0, 0, fmt.Errorf("volume %s: Reserved: %s", v.Path, err)
+		}
+	}
+	if v.Cap != "" {
+		if limit, err = parseByteSize(v.Cap); err != nil {
+			return 0, 0, fmt.Errorf("volume %s: Cap: %s", v.Path, err)
+		}
+		if limit == 0 {
+			return 0, 0, fmt.Errorf("volume %s: Cap must be above 0", v.Path)
+		}
+	}
+	return reserved, limit, nil
+}
+
+// validateVolumes checks that the volumes have distinct, non-nested absolute
+// paths and valid sizes.
+func (d *Datastore) validateVolumes() error {
+	for i, v := range d.Volumes {
+		if !filepath.IsAbs(v.Path) {
+			return fmt.Errorf("volume path %q must be absolute", v.Path)
+		}
+		if _, _, err := v.sizes(); err != nil {
+			return err
+		}
+		for _, o := range d.Volumes[:i] {
+			a, b := filepath.Clean(v.Path), filepath.Clean(o.Path)
+			if a == b || strings.HasPrefix(a, b+string(filepath.Separator)) ||
+				strings.HasPrefix(b, a+string(filepath.Separator)) {
+				return fmt.Errorf("volumes %s and %s overlap", o.Path, v.Path)
+			}
+		}
+	}
+	return nil
+}
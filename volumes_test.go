@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testVolumes() []Volume {
+	return []Volume{
+		{Path: "/mnt/disk1", Cap: "4TB"},
+		{Path: "/mnt/disk2", Reserved: "100GB"},
+	}
+}
+
+func TestAdvertisedCapacity(t *testing.T) {
+	free := map[string]uint64{
+		"/mnt/disk1": 6e12,
+		"/mnt/disk2": 2e12,
+	}
+	d := Datastore{StorageMax: "10GB", Volumes: testVolumes()}
+	total, caps, err := d.AdvertisedCapacity(func(path string) (uint64, error) {
+		v, ok := free[path]
+		if !ok {
+			return 0, fmt.Errorf("no such volume")
+		}
+		return v, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// disk1 is capped at 4TB, disk2 keeps 100GB free
+	if total != 5.9e12 {
+		t.Fatalf("unexpected total: %d", total)
+	}
+	if caps[0].Advertised != 4e12 || caps[1].Advertised != 1.9e12 || caps[1].Free != 2e12 {
+		t.Fatalf("unexpected volume capacities: %+v", caps)
+	}
+
+	d.Volumes = append(testVolumes(), Volume{Path: "/mnt/disk3", Reserved: "1TB"})
+	free["/mnt/disk3"] = 500e9
+	if total, _, err := d.AdvertisedCapacity(func(path string) (uint64, error) { return free[path], nil }); err != nil || total != 5.9e12 {
+		t.Fatalf("expected a full volume to add nothing, got %d, %v", total, err)
+	}
+
+	d.Volumes = nil
+	if total, _, err := d.AdvertisedCapacity(nil); err != nil || total != 10e9 {
+		t.Fatalf("expected StorageMax without volumes, got %d, %v", total, err)
+	}
+}
+
+func TestValidateVolumes(t *testing.T) {
+	for _, volumes := range [][]Volume{
+		{{Path: "mnt/disk1"}},
+		{{Path: "/mnt/disk1", Cap: "0"}},
+		{{Path: "/mnt/disk1", Reserved: "some"}},
+		{{Path: "/mnt/disk1"}, {Path: "/mnt/disk1/sub"}},
+		{{Path: "/mnt/disk1"}, {Path: "/mnt/disk1/"}},
+	} {
+		d := Datastore{Volumes: volumes}
+		if err := d.validateVolumes(); err == nil {
+			t.Errorf("expected %+v to be invalid", volumes)
+		}
+	}
+	d := Datastore{Volumes: []Volume{{Path: "/mnt/disk1"}, {Path: "/mnt/disk10"}, {Path: "/mnt/disk2"}}}
+	if err := d.validateVolumes(); err != nil {
+		t.Fatal(err)
+	}
+}
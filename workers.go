@@ -0,0 +1,82 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limits enforced on the Repair and Challenge settings.
+const (
+	MaxWorkerConcurrency = 256
+	MinWorkerJobTimeout  = time.Minute
+	MaxWorkerJobTimeout  = 24 * time.Hour
+	MinWorkerInterval    = time.Minute
+)
+
+// StorageWorker configures the repair or challenge jobs a node runs for the
+// network once the matching feature is enabled. Rewards are in µBTT.
+type StorageWorker struct {
+	// Concurrency is the number of jobs run in parallel.
+	Concurrency int
+
+	// JobTimeout bounds a single job.
+	JobTimeout Duration
+
+	// MaxBandwidth caps the bandwidth used by the jobs, in bytes per
+	// second such as "10MB". Empty means no cap.
+	MaxBandwidth string `json:",omitempty"`
+
+	// MinReward is the smallest reward for which a job is accepted.
+	MinReward int64
+
+	// Interval is the time between two polls for new jobs.
+	Interval Duration
+
+	// ActiveHours, when not empty, restricts the jobs to these local times
+	// of day.
+	ActiveHours []TimeOfDayRange `json:",omitempty"`
+}
+
+// Active reports whether jobs may run at t.
+func (w *StorageWorker) Active(t time.Time) bool {
+	if len(w.ActiveHours) == 0 {
+		return true
+	}
+	for _, r := range w.ActiveHours {
+		if r.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the ranges of the settings.
+func (w *StorageWorker) Validate() error {
+	if w.Concurrency < 1 || w.Concurrency > MaxWorkerConcurrency {
+		return fmt.Errorf("Concurrency must be between 1 and %d", MaxWorkerConcurrency)
+	}
+	if timeout := time.Duration(w.JobTimeout); timeout < MinWorkerJobTimeout || timeout > MaxWorkerJobTimeout {
+		return fmt.Errorf("JobTimeout must be between %s and %s", MinWorkerJobTimeout, MaxWorkerJobTimeout)
+	}
+	if w.MaxBandwidth != "" {
+		bw, err := parseByteSize(w.MaxBandwidth)
+		if err != nil {
+			return fmt.Errorf("MaxBandwidth: %s", err)
+		}
+		if bw == 0 {
+			return fmt.Errorf("MaxBandwidth must be above 0, leave it empty for no cap")
+		}
+	}
+	if w.MinReward < 0 {
+		return fmt.Errorf("MinReward must not be negative")
+	}
+	if time.Duration(w.Interval) < MinWorkerInterval {
+		return fmt.Errorf("Interval must be at least %s", MinWorkerInterval)
+	}
+	for _, r := range w.ActiveHours {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("ActiveHours: %s", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStorageWorkerDefaults(t *testing.T) {
+	for name, w := range map[string]StorageWorker{
+		"repair":            DefaultRepairConfig(),
+		"repair-testnet":    DefaultRepairConfigTestnet(),
+		"repair-dev":        DefaultRepairConfigDev(),
+		"challenge":         DefaultChallengeConfig(),
+		"challenge-testnet": DefaultChallengeConfigTestnet(),
+		"challenge-dev":     DefaultChallengeConfigDev(),
+	} {
+		if err := w.Validate(); err != nil {
+			t.Errorf("%s: %s", name, err)
+		}
+	}
+}
+
+func TestStorageWorkerValidate(t *testing.T) {
+	invalid := []func(w *StorageWorker){
+		func(w *StorageWorker) { w.Concurrency = 0 },
+		func(w *StorageWorker) { w.Concurrency = MaxWorkerConcurrency + 1 },
+		func(w *StorageWorker) { w.JobTimeout = Duration(time.Second) },
+		func(w *StorageWorker) { w.JobTimeout = Duration(48 * time.Hour) },
+		func(w *StorageWorker) { w.MaxBandwidth = "fast" },
+		func(w *StorageWorker) { w.MaxBandwidth = "0MB" },
+		func(w *StorageWorker) { w.MinReward = -1 },
+		func(w *StorageWorker) { w.Interval = Duration(time.Second) },
+		func(w *StorageWorker) { w.ActiveHours = []TimeOfDayRange{{"1:00", ""}} },
+	}
+	for i, mutate := range invalid {
+		w := DefaultRepairConfig()
+		mutate(&w)
+		if w.Validate() == nil {
+			t.Errorf("case %d: expected an error", i)
+		}
+	}
+}
+
+func TestStorageWorkerActive(t *testing.T) {
+	w := DefaultChallengeConfig()
+	noon := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	if !w.Active(noon) {
+		t.Fatal("expected jobs to run at any time without active hours")
+	}
+	w.ActiveHours = []TimeOfDayRange{{Start: "22:00", End: "06:00"}}
+	if w.Active(noon) {
+		t.Fatal("expected jobs to be paused outside the active hours")
+	}
+}
+
+func TestStorageWorkerProfiles(t *testing.T) {
+	for profile, expected := range map[string]StorageWorker{
+		"storage-repairer":         DefaultRepairConfig(),
+		"storage-repairer-testnet": DefaultRepairConfigTestnet(),
+		"storage-repairer-dev":     DefaultRepairConfigDev(),
+	} {
+		cfg := &Config{Datastore: DefaultDatastoreConfig()}
+		if err := Profiles[profile].Transform(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg.Repair, expected) {
+			t.Errorf("%s: unexpected Repair %+v", profile, cfg.Repair)
+		}
+	}
+	for profile, expected := range map[string]StorageWorker{
+		"storage-challenger":         DefaultChallengeConfig(),
+		"storage-challenger-testnet": DefaultChallengeConfigTestnet(),
+		"storage-challenger-dev":     DefaultChallengeConfigDev(),
+	} {
+		cfg := &Config{Datastore: DefaultDatastoreConfig()}
+		if err := Profiles[profile].Transform(cfg); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg.Challenge, expected) {
+			t.Errorf("%s: unexpected Challenge %+v", profile, cfg.Challenge)
+		}
+	}
+}